@@ -2,37 +2,138 @@ package assemblyai
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/url"
 	"os"
 	"path"
 	"path/filepath"
+	"strings"
+	"text/template"
 	"time"
 
+	"github.com/deepakjois/podscript/internal/audio"
+	"github.com/deepakjois/podscript/internal/config"
+	"github.com/deepakjois/podscript/internal/download"
+	"github.com/deepakjois/podscript/internal/llm"
+	"github.com/deepakjois/podscript/internal/output"
+	"github.com/deepakjois/podscript/internal/stt"
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
+	"github.com/tmc/langchaingo/llms"
 
 	aai "github.com/AssemblyAI/assemblyai-go-sdk"
 )
 
 const (
 	maxLocalFileSize int64 = 2200 * 1024 * 1024 // Approximate 2.2GB in bytes
+
+	// Price of the best model as of Oct 2024 ($0.37 per hour)
+	costPerMinute = 0.37 / 60
+
+	// maxSpeakersExpected is the largest number of speakers accepted by
+	// speakers_expected
+	maxSpeakersExpected = 10
+
+	// Speech models that are newer than the SDK
+	speechModelUniversal aai.SpeechModel = "universal"
+	speechModelSlam1     aai.SpeechModel = "slam-1"
 )
 
+// speechModels lists the speech models that can be selected with --model.
+var speechModels = []aai.SpeechModel{speechModelUniversal, speechModelSlam1, aai.SpeechModelBest, aai.SpeechModelNano}
+
+func speechModelList() string {
+	models := make([]string, len(speechModels))
+	for i, m := range speechModels {
+		models[i] = string(m)
+	}
+	return strings.Join(models, ", ")
+}
+
+func validSpeechModel(model string) bool {
+	for _, m := range speechModels {
+		if string(m) == model {
+			return true
+		}
+	}
+	return false
+}
+
 func init() {
 	Command.Flags().StringP("path", "p", "", "save transcripts and API responses to path")
 	Command.Flags().StringP("suffix", "s", "", "append suffix to filenames for easier recognition")
+	Command.Flags().String("api-key", "", "API key (overrides environment and config)")
+	Command.Flags().StringP("model", "m", "", fmt.Sprintf("speech model - one of %s (default chosen by Assembly AI)", speechModelList()))
 	Command.Flags().BoolP("verbose", "v", false, "fetch verbose JSON response (includes token and start/end timestamps)")
 	Command.Flags().StringP("from-url", "u", "", "URL of the audio file to transcribe")
 	Command.Flags().StringP("from-file", "f", "", "Local path to the audio file to transcribe")
+	Command.Flags().String("vocab", "", "file with terms (one per line) to bias transcription towards, for e.g. names and jargon")
+	Command.Flags().Bool("trim-silence", false, "remove long silences from local audio before transcribing (requires ffmpeg)")
+	Command.Flags().StringArray("concat", nil, "local audio file to join to the end of --from-file before transcribing (can be repeated, requires ffmpeg)")
+	Command.Flags().Bool("download-first", false, "download the audio from --from-url and upload it, for URLs that Assembly AI can't fetch (for e.g. signed or authenticated URLs)")
+	Command.Flags().StringArray("download-header", nil, "HTTP header to send when downloading with --download-first, for e.g. \"Authorization: Bearer token\" (can be repeated)")
+	Command.Flags().String("max-file-size", "", "with --download-first, stop downloading files larger than this, for e.g. 500MB")
+	Command.Flags().Duration("max-duration", 0, "with --download-first, don't transcribe audio longer than this, for e.g. 2h (requires ffmpeg)")
+	Command.Flags().Bool("estimate", false, "print the audio duration and estimated cost, without transcribing")
+	Command.Flags().BoolP("json", "j", false, "also save the raw JSON API response (includes words, confidences and chapters)")
+	Command.Flags().BoolP("combine", "c", false, "merge consecutive utterances from the same speaker into a single paragraph")
+	Command.Flags().Bool("infer-speakers", false, "use an LLM to infer speaker names from the transcript")
+	Command.Flags().String("infer-model", string(llm.ChatGpt4oMini), "LLM used by --infer-speakers")
+	Command.Flags().String("format", stt.FormatText, fmt.Sprintf("output format - one of %s", strings.Join(stt.Formats, ", ")))
+	Command.Flags().Bool("embed", false, "save a copy of the local audio file with the transcript and chapters in its metadata (requires ffmpeg)")
+	Command.Flags().Duration("timeout", 0, "give up waiting for the transcript after this long, for e.g. 2h (default no timeout)")
+	Command.Flags().String("resume-id", "", "resume waiting for a previously submitted transcript with this ID, instead of submitting audio")
+	Command.Flags().String("get", "", "fetch and write the completed transcript with this ID, for e.g. one submitted elsewhere, instead of submitting audio")
+	Command.Flags().Float64("min-confidence", 0, "mark words transcribed with a confidence below this (between 0 and 1) with [?]")
+	Command.Flags().Bool("confidence", false, "prefix each utterance with its confidence score")
+	Command.Flags().Bool("multilingual", false, "detect the spoken language instead of assuming English")
+	Command.Flags().StringSlice("language", nil, "comma-separated language codes to try in turn, for e.g. en,es - the audio is transcribed again in the next language if confidence is low")
+	Command.Flags().Int("speakers-expected", 0, "number of speakers in the audio, if known, to improve speaker labels")
+	Command.Flags().String("template", "", "also write the transcript using this Go text/template, for e.g. to add front matter (see README)")
+	Command.Flags().String("split-by-speaker", "", fmt.Sprintf("also write a file per speaker with only their utterances - %s (the default, with the combined transcript) or %s (instead of it)", stt.SplitBoth, stt.SplitOnly))
+	Command.Flags().Lookup("split-by-speaker").NoOptDefVal = stt.SplitBoth
+}
+
+// downloadLimits returns the limits on files downloaded with --download-first.
+// Files are never larger than the limit for uploads.
+func downloadLimits(cmd *cobra.Command) (download.Limits, error) {
+	limits := download.Limits{MaxSize: maxLocalFileSize}
+	if size, _ := cmd.Flags().GetString("max-file-size"); size != "" {
+		maxSize, err := download.ParseSize(size)
+		if err != nil {
+			return limits, fmt.Errorf("invalid --max-file-size: %w", err)
+		}
+		if maxSize > 0 {
+			limits.MaxSize = min(maxSize, maxLocalFileSize)
+		}
+	}
+	limits.MaxDuration, _ = cmd.Flags().GetDuration("max-duration")
+	return limits, nil
 }
 
 var Command = &cobra.Command{
 	Use:   "assemblyai",
 	Short: "Generate transcript of an audio file using Assembly AI's API.",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		apiKey := viper.GetString("assemblyai_api_key")
+		if estimate, _ := cmd.Flags().GetBool("estimate"); estimate {
+			audioURL, _ := cmd.Flags().GetString("from-url")
+			audioFilePath, _ := cmd.Flags().GetString("from-file")
+			if audioURL == "" && audioFilePath == "" {
+				return errors.New("please provide either a valid URL or a file path")
+			}
+			input := audioURL
+			if input == "" {
+				input = audioFilePath
+			}
+			audio.PrintEstimate(context.Background(), input, costPerMinute)
+			return nil
+		}
+
+		if apiKey, _ := cmd.Flags().GetString("api-key"); apiKey != "" {
+			config.SetAPIKey(config.AssemblyAI, apiKey)
+		}
+		apiKey := config.APIKey(config.AssemblyAI)
 		if apiKey == "" {
 			return errors.New("assembly AI's API key not found. Please run 'podscript configure' or set the ASSEMBLYAI_API_KEY environment variable")
 		}
@@ -42,14 +143,76 @@ var Command = &cobra.Command{
 		audioURL, _ := cmd.Flags().GetString("from-url")
 		audioFilePath, _ := cmd.Flags().GetString("from-file")
 		verbose, _ := cmd.Flags().GetBool("verbose")
+		combine, _ := cmd.Flags().GetBool("combine")
+		saveJSON, _ := cmd.Flags().GetBool("json")
+		split, _ := cmd.Flags().GetString("split-by-speaker")
+		if !stt.ValidSplit(split) {
+			return fmt.Errorf("invalid --split-by-speaker: must be one of %s, %s", stt.SplitBoth, stt.SplitOnly)
+		}
+		inferSpeakers, _ := cmd.Flags().GetBool("infer-speakers")
+		inferModel, _ := cmd.Flags().GetString("infer-model")
+		// The model is initialized before the audio is transcribed, so that
+		// for e.g. a missing API key doesn't waste a paid transcription
+		var inferLLM llms.Model
+		if inferSpeakers {
+			if !llm.Supported(llm.Model(inferModel)) {
+				return fmt.Errorf("invalid model for --infer-speakers: %s", inferModel)
+			}
+			var err error
+			if inferLLM, err = llm.New(llm.Model(inferModel)); err != nil {
+				return fmt.Errorf("failed to initialize model %s: %w", inferModel, err)
+			}
+		}
+		format, _ := cmd.Flags().GetString("format")
+		if !stt.ValidFormat(format) {
+			return fmt.Errorf("invalid format: must be one of %s", strings.Join(stt.Formats, ", "))
+		}
+		model, _ := cmd.Flags().GetString("model")
+		if model != "" && !validSpeechModel(model) {
+			return fmt.Errorf("invalid model: must be one of %s", speechModelList())
+		}
+		speakersExpected, _ := cmd.Flags().GetInt("speakers-expected")
+		if cmd.Flags().Changed("speakers-expected") && (speakersExpected < 1 || speakersExpected > maxSpeakersExpected) {
+			return fmt.Errorf("invalid --speakers-expected: must be between 1 and %d", maxSpeakersExpected)
+		}
+
+		getID, _ := cmd.Flags().GetString("get")
+		if getID != "" && (audioURL != "" || audioFilePath != "" || cmd.Flags().Changed("resume-id")) {
+			return errors.New("--get cannot be used with --from-url, --from-file or --resume-id")
+		}
+		languages, _ := cmd.Flags().GetStringSlice("language")
+		if len(languages) > 0 && (getID != "" || cmd.Flags().Changed("resume-id") || cmd.Flags().Changed("multilingual")) {
+			return errors.New("--language cannot be used with --get, --resume-id or --multilingual")
+		}
+
+		embed, _ := cmd.Flags().GetBool("embed")
+		if embed && audioFilePath == "" {
+			return errors.New("--embed requires --from-file")
+		}
+		concat, _ := cmd.Flags().GetStringArray("concat")
+		if len(concat) > 0 && audioFilePath == "" {
+			return errors.New("--concat requires --from-file")
+		}
+		if len(concat) > 0 && embed {
+			return errors.New("--concat can't be used with --embed")
+		}
 
 		if folder == "" {
 			folder = "." // Default to current directory if no path is specified
 		}
 
+		var tmpl *template.Template
+		templateFile, _ := cmd.Flags().GetString("template")
+		if templateFile != "" {
+			var err error
+			if tmpl, err = stt.ReadTemplate(templateFile); err != nil {
+				return err
+			}
+		}
+
 		folder = filepath.Clean(folder)
-		if fi, err := os.Stat(folder); err != nil || !fi.IsDir() {
-			return fmt.Errorf("path not found: %s", folder)
+		if err := output.CheckDir(folder); err != nil {
+			return err
 		}
 
 		timestamp := time.Now().Format("2006-01-02-150405")
@@ -61,77 +224,292 @@ var Command = &cobra.Command{
 		client := aai.NewClient(apiKey)
 		ctx := context.Background()
 
-		var transcript *aai.Transcript
-		var err error
+		var (
+			transcript   *aai.Transcript
+			transcriptID string
+			timingMap    audio.TimingMap
+			err          error
+		)
 
-		if audioURL != "" {
+		params := &aai.TranscriptOptionalParams{
+			SpeakerLabels: aai.Bool(true),
+			Punctuate:     aai.Bool(true),
+			FormatText:    aai.Bool(true),
+		}
+		if model != "" {
+			params.SpeechModel = aai.SpeechModel(model)
+		}
+		if speakersExpected > 0 {
+			params.SpeakersExpected = aai.Int64(int64(speakersExpected))
+		}
+		if vocab, _ := cmd.Flags().GetString("vocab"); vocab != "" {
+			terms, err := stt.ReadVocabulary(vocab)
+			if err != nil {
+				return err
+			}
+			params.WordBoost = terms
+		}
+		if multilingual, _ := cmd.Flags().GetBool("multilingual"); multilingual {
+			params.LanguageDetection = aai.Bool(true)
+		}
+		if len(languages) > 0 {
+			params.LanguageCode = aai.TranscriptLanguageCode(languages[0])
+		}
+		if embed {
+			params.AutoChapters = aai.Bool(true)
+		}
+
+		resumeID, _ := cmd.Flags().GetString("resume-id")
+		if getID != "" {
+			transcriptID = getID
+		} else if resumeID != "" {
+			transcriptID = resumeID
+		} else if downloadFirst, _ := cmd.Flags().GetBool("download-first"); downloadFirst && audioURL != "" {
+			headers, _ := cmd.Flags().GetStringArray("download-header")
+			header, err := download.ParseHeaders(headers)
+			if err != nil {
+				return err
+			}
+			limits, err := downloadLimits(cmd)
+			if err != nil {
+				return err
+			}
+			downloaded, err := download.File(ctx, audioURL, header, limits)
+			if err != nil {
+				return err
+			}
+			defer os.Remove(downloaded)
+			fmt.Printf("Downloaded %s\n", audioURL)
+
+			file, err := os.Open(downloaded)
+			if err != nil {
+				return fmt.Errorf("error opening file: %w", err)
+			}
+			defer file.Close()
+
+			submitted, err := client.Transcripts.SubmitFromReader(ctx, file, params)
+			if err != nil {
+				return fmt.Errorf("failed to transcribe from file: %w", err)
+			}
+			transcriptID = aai.ToString(submitted.ID)
+		} else if audioURL != "" {
 			// Handle URL input
 			parsedURL, err := url.ParseRequestURI(audioURL)
 			if err != nil || (parsedURL.Scheme != "http" && parsedURL.Scheme != "https") {
 				return fmt.Errorf("invalid URL: %s", audioURL)
 			}
 
-			params := &aai.TranscriptOptionalParams{
-				SpeakerLabels: aai.Bool(true),
-				Punctuate:     aai.Bool(true),
-				FormatText:    aai.Bool(true),
-			}
-			transcriptValue, err := client.Transcripts.TranscribeFromURL(ctx, audioURL, params)
+			submitted, err := client.Transcripts.SubmitFromURL(ctx, audioURL, params)
 			if err != nil {
 				return fmt.Errorf("failed to transcribe from URL: %w", err)
 			}
-			transcript = &transcriptValue
-			fmt.Printf("Generated transcript from URL %s\n", audioURL)
+			transcriptID = aai.ToString(submitted.ID)
 
 		} else if audioFilePath != "" {
 			// Handle file input
-			audioFilePath := filepath.Clean(audioFilePath)
+			audioFilePath = filepath.Clean(audioFilePath)
 			fi, err := os.Stat(audioFilePath)
 			if err != nil || fi.IsDir() {
 				return fmt.Errorf("invalid audio file: %s", audioFilePath)
 			}
 
+			uploadPath := audioFilePath
+			if len(concat) > 0 {
+				joined, err := audio.Concat(ctx, append([]string{audioFilePath}, concat...))
+				if err != nil {
+					return fmt.Errorf("failed to concatenate audio: %w", err)
+				}
+				defer os.Remove(joined)
+				if fi, err = os.Stat(joined); err != nil {
+					return fmt.Errorf("failed to concatenate audio: %w", err)
+				}
+				uploadPath = joined
+				fmt.Printf("concatenated %d audio files\n", len(concat)+1)
+			}
+
 			if fi.Size() > maxLocalFileSize {
 				return fmt.Errorf("file size exceeds 2.2GB limit")
 			}
 
-			file, err := os.Open(audioFilePath)
+			if trimSilence, _ := cmd.Flags().GetBool("trim-silence"); trimSilence {
+				var trimmed string
+				trimmed, timingMap, err = audio.TrimSilence(ctx, uploadPath)
+				if err != nil {
+					return fmt.Errorf("failed to trim silence: %w", err)
+				}
+				defer os.Remove(trimmed)
+				uploadPath = trimmed
+
+				timingMapFilename := path.Join(folder, fmt.Sprintf("assemblyai_timing_map_%s.json", filenameSuffix))
+				if err = timingMap.WriteFile(timingMapFilename); err != nil {
+					return fmt.Errorf("failed to write timing map: %w", err)
+				}
+				fmt.Printf("trimmed %d silences, wrote timing map to %s\n", len(timingMap.Cuts), timingMapFilename)
+			}
+
+			file, err := os.Open(uploadPath)
 			if err != nil {
 				return fmt.Errorf("error opening file: %w", err)
 			}
 			defer file.Close()
 
-			transcriptValue, err := client.Transcripts.TranscribeFromReader(ctx, file, nil)
+			submitted, err := client.Transcripts.SubmitFromReader(ctx, file, params)
 			if err != nil {
 				return fmt.Errorf("failed to transcribe from file: %w", err)
 			}
-			transcript = &transcriptValue
+			transcriptID = aai.ToString(submitted.ID)
 		} else {
-			return errors.New("please provide either a valid URL or a file path")
+			return errors.New("please provide either a valid URL, a file path or a transcript ID to resume")
 		}
 
-		if transcript == nil || transcript.Text == nil {
+		if resumeID == "" && getID == "" {
+			idFilename := path.Join(folder, fmt.Sprintf("assemblyai_transcript_id_%s.txt", filenameSuffix))
+			if err = os.WriteFile(idFilename, []byte(transcriptID+"\n"), 0644); err != nil {
+				return fmt.Errorf("failed to write transcript ID: %w", err)
+			}
+			fmt.Printf("Submitted transcript %s, wrote ID to %s (use --resume-id if interrupted)\n", transcriptID, idFilename)
+		}
+
+		waitCtx := ctx
+		if timeout, _ := cmd.Flags().GetDuration("timeout"); timeout > 0 {
+			var cancel context.CancelFunc
+			waitCtx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+		var transcriptValue aai.Transcript
+		if getID != "" {
+			transcriptValue, err = getTranscript(ctx, client, getID)
+		} else {
+			transcriptValue, err = waitForTranscript(waitCtx, client, transcriptID)
+		}
+		if err == nil && len(languages) > 1 {
+			transcriptValue, err = retryLanguages(waitCtx, client, transcriptValue, params, languages)
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			return fmt.Errorf("timed out waiting for transcript, run again with --resume-id %s to keep waiting", transcriptID)
+		} else if err != nil {
+			return err
+		}
+		transcript = &transcriptValue
+
+		if transcript.Text == nil {
 			return errors.New("transcription failed: received nil transcript from AssemblyAI API")
 		}
+		if transcript.LanguageConfidence != nil {
+			fmt.Printf("detected language %s (confidence %.2f)\n", transcript.LanguageCode, aai.ToFloat64(transcript.LanguageConfidence))
+		}
 
-		transcriptFilename := path.Join(folder, fmt.Sprintf("assemblyai_transcript_%s.txt", filenameSuffix))
-		transcriptFilename = filepath.Clean(transcriptFilename)
-		file, err := os.Create(transcriptFilename)
-		if err != nil {
-			return fmt.Errorf("failed to create transcript file: %w", err)
+		if saveJSON {
+			data, err := json.Marshal(transcript)
+			if err != nil {
+				return fmt.Errorf("json.Marshal failed: %w", err)
+			}
+			jsonFilename := filepath.Clean(path.Join(folder, fmt.Sprintf("assemblyai_api_response_%s.json", filenameSuffix)))
+			if err = os.WriteFile(jsonFilename, data, 0644); err != nil {
+				return fmt.Errorf("failed to write JSON response: %w", err)
+			}
+			fmt.Printf("Wrote raw JSON API response to %s\n", jsonFilename)
 		}
-		defer file.Close()
 
-		for _, utterance := range transcript.Utterances {
-			_, err := fmt.Fprintf(file, "Speaker %s: %s\n\n",
-				aai.ToString(utterance.Speaker),
-				aai.ToString(utterance.Text),
-			)
+		utterances := stt.MapTimes(stt.AssemblyAIUtterances(transcript), timingMap.Original)
+		if combine {
+			utterances = stt.Combine(utterances)
+		}
+		if inferSpeakers {
+			names, err := stt.InferSpeakerNames(ctx, inferLLM, utterances)
+			if err != nil {
+				return err
+			}
+			if confirmed, err := stt.ConfirmSpeakerNames(names); err != nil {
+				return err
+			} else if confirmed {
+				utterances = stt.NameSpeakers(utterances, names)
+			}
+		}
+		// Annotations are only added to the written transcript
+		annotated := utterances
+		if minConfidence, _ := cmd.Flags().GetFloat64("min-confidence"); minConfidence > 0 {
+			annotated = stt.MarkLowConfidence(annotated, minConfidence)
+		}
+		if showConfidence, _ := cmd.Flags().GetBool("confidence"); showConfidence {
+			annotated = stt.AnnotateConfidence(annotated)
+		}
+		title := audioURL
+		if title == "" {
+			title = audioFilePath
+		}
+		if title == "" {
+			// Resumed or fetched by ID
+			title = transcriptID
+		}
+		if split != "" {
+			filenames, err := stt.WriteBySpeaker(func(speaker string) string {
+				return filepath.Clean(path.Join(folder, fmt.Sprintf("assemblyai_transcript_%s_%s.%s", filenameSuffix, speaker, stt.Extension(format))))
+			}, format, title, annotated)
+			if err != nil {
+				return err
+			}
+			for _, f := range filenames {
+				fmt.Printf("Wrote speaker transcript to %s\n", f)
+			}
+		}
+		if split != stt.SplitOnly {
+			transcriptFilename := path.Join(folder, fmt.Sprintf("assemblyai_transcript_%s.%s", filenameSuffix, stt.Extension(format)))
+			transcriptFilename = filepath.Clean(transcriptFilename)
+			file, err := output.Create(transcriptFilename)
 			if err != nil {
-				return fmt.Errorf("failed to write utterance to file: %w", err)
+				return fmt.Errorf("failed to create transcript file: %w", err)
+			}
+			defer file.Close()
+			if err := stt.Write(file, format, title, annotated); err != nil {
+				return err
+			}
+			fmt.Printf("Wrote transcript to %s\n", transcriptFilename)
+		}
+
+		if tmpl != nil {
+			var text strings.Builder
+			if err := stt.WriteText(&text, utterances); err != nil {
+				return err
+			}
+			data := stt.TemplateData{
+				Title:      filepath.Base(title),
+				URL:        audioURL,
+				Model:      string(transcript.SpeechModel),
+				Date:       time.Now(),
+				Transcript: text.String(),
+				Segments:   stt.SegmentsFromUtterances(utterances),
+				Utterances: utterances,
+			}
+			if audioURL != "" {
+				data.Title = audioURL
+			}
+			templateFilename := filepath.Clean(path.Join(folder, fmt.Sprintf("assemblyai_templated_transcript_%s.%s", filenameSuffix, stt.TemplateExtension(templateFile))))
+			if err := stt.WriteTemplate(templateFilename, tmpl, data); err != nil {
+				return err
+			}
+			fmt.Printf("Wrote templated transcript to %s\n", templateFilename)
+		}
+
+		if embed {
+			var text strings.Builder
+			if err := stt.WriteText(&text, utterances); err != nil {
+				return err
+			}
+			var chapters []audio.Chapter
+			for _, c := range transcript.Chapters {
+				chapters = append(chapters, audio.Chapter{
+					Start: timingMap.Original(float64(aai.ToInt64(c.Start)) / 1000),
+					End:   timingMap.Original(float64(aai.ToInt64(c.End)) / 1000),
+					Title: aai.ToString(c.Gist),
+				})
+			}
+			embedFilename := audio.EmbedFilename(audioFilePath, folder, filenameSuffix)
+			if err := audio.Embed(ctx, audioFilePath, embedFilename, text.String(), chapters); err != nil {
+				return fmt.Errorf("failed to embed transcript: %w", err)
 			}
+			fmt.Printf("Wrote audio with embedded transcript to %s\n", embedFilename)
 		}
-		fmt.Printf("Wrote transcript to %s\n", transcriptFilename)
 
 		if verbose {
 			fmt.Printf("Transcript metadata: %+v\n", transcript)
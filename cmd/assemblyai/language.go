@@ -0,0 +1,39 @@
+package assemblyai
+
+import (
+	"context"
+	"fmt"
+
+	aai "github.com/AssemblyAI/assemblyai-go-sdk"
+)
+
+// minLanguageConfidence is the confidence of a transcript below which it is
+// transcribed again in the next language given with --language.
+const minLanguageConfidence = 0.7
+
+// retryLanguages transcribes the audio of transcript, which is in the first
+// of languages, again in each of the other languages in turn until the
+// confidence of the transcript is high enough. It returns the most confident
+// transcript. The audio is transcribed from the URL it was uploaded to, so
+// it is not uploaded again.
+func retryLanguages(ctx context.Context, client *aai.Client, transcript aai.Transcript, params *aai.TranscriptOptionalParams, languages []string) (aai.Transcript, error) {
+	best, bestLanguage := transcript, languages[0]
+	confidence := aai.ToFloat64(transcript.Confidence)
+	for i := 1; i < len(languages) && confidence < minLanguageConfidence; i++ {
+		fmt.Printf("Confidence of the %s transcript is %.2f, transcribing again in %s\n", languages[i-1], confidence, languages[i])
+		params.LanguageCode = aai.TranscriptLanguageCode(languages[i])
+		submitted, err := client.Transcripts.SubmitFromURL(ctx, aai.ToString(transcript.AudioURL), params)
+		if err != nil {
+			return best, fmt.Errorf("failed to transcribe in %s: %w", languages[i], err)
+		}
+		retried, err := waitForTranscript(ctx, client, aai.ToString(submitted.ID))
+		if err != nil {
+			return best, err
+		}
+		if confidence = aai.ToFloat64(retried.Confidence); confidence > aai.ToFloat64(best.Confidence) {
+			best, bestLanguage = retried, languages[i]
+		}
+	}
+	fmt.Printf("Transcribed in %s (confidence %.2f)\n", bestLanguage, aai.ToFloat64(best.Confidence))
+	return best, nil
+}
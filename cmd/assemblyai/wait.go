@@ -0,0 +1,62 @@
+package assemblyai
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	aai "github.com/AssemblyAI/assemblyai-go-sdk"
+)
+
+// pollInterval is the time between checks on the status of a transcript.
+const pollInterval = 5 * time.Second
+
+// waitForTranscript polls the status of the transcript with id until it is
+// completed or fails, showing progress while it waits. Unlike the SDK's Wait,
+// it keeps polling for as long as ctx allows, so long uploads don't time out.
+func waitForTranscript(ctx context.Context, client *aai.Client, id string) (aai.Transcript, error) {
+	start := time.Now()
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		transcript, err := client.Transcripts.Get(ctx, id)
+		if err != nil {
+			fmt.Println()
+			return transcript, fmt.Errorf("failed to get transcript %s: %w", id, err)
+		}
+		switch transcript.Status {
+		case "completed":
+			fmt.Println()
+			return transcript, nil
+		case "error":
+			fmt.Println()
+			return transcript, fmt.Errorf("transcription failed: %s", aai.ToString(transcript.Error))
+		}
+		fmt.Printf("\rtranscript is %s (%s elapsed)…", transcript.Status, time.Since(start).Round(time.Second))
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			fmt.Println()
+			return transcript, ctx.Err()
+		}
+	}
+}
+
+// getTranscript fetches the transcript with id without waiting for it,
+// failing if it is not completed yet.
+func getTranscript(ctx context.Context, client *aai.Client, id string) (aai.Transcript, error) {
+	transcript, err := client.Transcripts.Get(ctx, id)
+	if err != nil {
+		return transcript, fmt.Errorf("failed to get transcript %s: %w", id, err)
+	}
+	switch transcript.Status {
+	case "completed":
+		return transcript, nil
+	case "error":
+		return transcript, fmt.Errorf("transcription failed: %s", aai.ToString(transcript.Error))
+	default:
+		return transcript, fmt.Errorf("transcript %s is still %s, run again with --resume-id %s to wait for it", id, transcript.Status, id)
+	}
+}
@@ -0,0 +1,169 @@
+package batch
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// backendArgs returns the arguments to transcribe file with each of the
+// subcommands that can be used by batch.
+var backendArgs = map[string]func(file string) []string{
+	"deepgram":   func(file string) []string { return []string{"deepgram", file, "--from-file"} },
+	"assemblyai": func(file string) []string { return []string{"assemblyai", "--from-file", file} },
+	"groq":       func(file string) []string { return []string{"groq", file} },
+	"openai":     func(file string) []string { return []string{"openai", file} },
+	"external":   func(file string) []string { return []string{"external", file} },
+}
+
+const backends = "deepgram, assemblyai, groq, openai, external"
+
+func init() {
+	Command.Flags().StringP("backend", "b", "", fmt.Sprintf("subcommand used to transcribe each file - one of %s", backends))
+	Command.Flags().StringP("path", "p", "", "save transcripts to path, in folders mirroring the input directory (default alongside each audio file)")
+	Command.Flags().StringSlice("ext", []string{"mp3", "m4a", "wav", "flac", "ogg", "opus", "aac", "mp4"}, "extensions of the audio files to transcribe")
+	Command.Flags().Bool("dry-run", false, "list the files that would be transcribed, without transcribing them")
+	Command.MarkFlagRequired("backend")
+}
+
+// hasTranscript reports whether folder already has a transcript of the audio
+// file with the base name base, written with base as the suffix, for e.g.
+// deepgram_transcript_2024-07-05-170548_<base>.txt.
+func hasTranscript(folder, base string) bool {
+	entries, err := os.ReadDir(folder)
+	if err != nil {
+		return false
+	}
+	transcriptRegex := regexp.MustCompile(`_transcript_\d{4}-\d{2}-\d{2}-\d{6}_` + regexp.QuoteMeta(base) + `$`)
+	for _, e := range entries {
+		name := e.Name()
+		if transcriptRegex.MatchString(strings.TrimSuffix(name, filepath.Ext(name))) {
+			return true
+		}
+	}
+	return false
+}
+
+// rootFlagArgs returns the flags of the root command that were set, for e.g.
+// --config or --header, so that they are passed on to the backend too.
+func rootFlagArgs(cmd *cobra.Command) []string {
+	var args []string
+	cmd.Root().PersistentFlags().VisitAll(func(f *pflag.Flag) {
+		if !f.Changed {
+			return
+		}
+		if values, ok := f.Value.(pflag.SliceValue); ok {
+			// Repeatable flags are passed once per value
+			for _, v := range values.GetSlice() {
+				args = append(args, fmt.Sprintf("--%s=%s", f.Name, v))
+			}
+			return
+		}
+		args = append(args, fmt.Sprintf("--%s=%s", f.Name, f.Value.String()))
+	})
+	return args
+}
+
+var Command = &cobra.Command{
+	Use:   "batch <directory> [-- <flags for the backend>]",
+	Short: "Transcribe every audio file in a directory and its subdirectories.",
+	Long: `Transcribe every audio file in a directory and its subdirectories, using
+one of the other subcommands.
+
+Each file is transcribed by running podscript with the backend subcommand, and
+the name of the audio file as the suffix of the transcript. Global flags, for
+e.g. --config or --header, are passed on to it. Files that already
+have a transcript are skipped. Flags after -- are passed to the backend, for
+e.g. podscript batch episodes --backend deepgram -- --combine.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		backend, _ := cmd.Flags().GetString("backend")
+		argsFor, ok := backendArgs[backend]
+		if !ok {
+			return fmt.Errorf("invalid backend: must be one of %s", backends)
+		}
+		root := args[0]
+		extraArgs := args[1:]
+		if cmd.ArgsLenAtDash() != 1 && len(extraArgs) > 0 {
+			return errors.New("flags for the backend must be passed after --")
+		}
+		outRoot, _ := cmd.Flags().GetString("path")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		exts := make(map[string]bool)
+		extList, _ := cmd.Flags().GetStringSlice("ext")
+		for _, ext := range extList {
+			exts["."+strings.ToLower(strings.TrimPrefix(ext, "."))] = true
+		}
+
+		rootArgs := rootFlagArgs(cmd)
+		executable, err := os.Executable()
+		if err != nil {
+			return fmt.Errorf("failed to find podscript executable: %w", err)
+		}
+
+		var transcribed, skipped, failed int
+		err = filepath.WalkDir(root, func(file string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() || !exts[strings.ToLower(filepath.Ext(file))] {
+				return nil
+			}
+
+			folder := filepath.Dir(file)
+			if outRoot != "" {
+				rel, err := filepath.Rel(root, folder)
+				if err != nil {
+					return err
+				}
+				folder = filepath.Join(outRoot, rel)
+			}
+			base := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+			if hasTranscript(folder, base) {
+				fmt.Printf("skipping %s, transcript exists in %s\n", file, folder)
+				skipped++
+				return nil
+			}
+			if dryRun {
+				fmt.Printf("would transcribe %s to %s\n", file, folder)
+				return nil
+			}
+
+			if err := os.MkdirAll(folder, 0755); err != nil {
+				return fmt.Errorf("failed to create folder: %w", err)
+			}
+			fmt.Printf("transcribing %s…\n", file)
+			childArgs := append(argsFor(file), "--path", folder, "--suffix", base)
+			childArgs = append(append(childArgs, rootArgs...), extraArgs...)
+			c := exec.Command(executable, childArgs...)
+			c.Stdin, c.Stdout, c.Stderr = os.Stdin, os.Stdout, os.Stderr
+			if err := c.Run(); err != nil {
+				fmt.Printf("failed to transcribe %s: %v\n", file, err)
+				failed++
+				return nil
+			}
+			transcribed++
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		if dryRun {
+			return nil
+		}
+		fmt.Printf("transcribed %d files, skipped %d, failed %d\n", transcribed, skipped, failed)
+		if failed > 0 {
+			return fmt.Errorf("failed to transcribe %d files", failed)
+		}
+		return nil
+	},
+}
@@ -0,0 +1,124 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	cfg "github.com/deepakjois/podscript/internal/config"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// tableKeys are config keys that hold a table of settings rather than a value.
+var tableKeys = []string{"models", "token-limits"}
+
+// flagKeys maps config keys to the global flags that override them.
+var flagKeys = map[string]string{
+	"ca_cert": "ca-cert",
+}
+
+var showCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Print the effective config, with secrets masked and the source of each value",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if location, _ := cmd.Flags().GetString("config"); location != "" {
+			fmt.Printf("config: %s\n\n", location)
+		} else if file := viper.ConfigFileUsed(); file != "" {
+			fmt.Printf("config: %s\n\n", file)
+		}
+
+		keys := append([]string(nil), cfg.EnvKeys...)
+		for _, k := range viper.AllKeys() {
+			if !contains(keys, k) {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "KEY\tVALUE\tSOURCE")
+		var unknown []string
+		for _, k := range keys {
+			if !known(k) {
+				unknown = append(unknown, k)
+			}
+			value, source := resolve(cmd, k)
+			if source == "" {
+				continue
+			}
+			if secret(k) {
+				value = mask(value)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\n", k, value, source)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+
+		for _, k := range unknown {
+			fmt.Printf("warning: unknown config key %s\n", k)
+		}
+		return nil
+	},
+}
+
+// resolve returns the value of key and where it was set: flag, env or file.
+// The source is empty if the key is not set.
+func resolve(cmd *cobra.Command, key string) (string, string) {
+	if flag, ok := flagKeys[key]; ok && cmd.Flags().Changed(flag) {
+		value, _ := cmd.Flags().GetString(flag)
+		return value, "flag"
+	}
+	if contains(cfg.EnvKeys, key) {
+		if value, ok := os.LookupEnv(strings.ToUpper(key)); ok {
+			return value, "env"
+		}
+	}
+	if viper.InConfig(key) {
+		return fmt.Sprint(viper.Get(key)), "file"
+	}
+	return "", ""
+}
+
+func known(key string) bool {
+	if contains(cfg.EnvKeys, key) {
+		return true
+	}
+	table, _, _ := strings.Cut(key, ".")
+	return contains(tableKeys, table)
+}
+
+// secret reports whether the value of key should be masked.
+func secret(key string) bool {
+	return strings.HasSuffix(key, "_key") || strings.Contains(key, "token") || strings.Contains(key, "secret")
+}
+
+// mask hides all but the last four characters of a secret value.
+func mask(value string) string {
+	if len(value) <= 8 {
+		return strings.Repeat("*", len(value))
+	}
+	return strings.Repeat("*", 8) + value[len(value)-4:]
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+var Command = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect the podscript config",
+}
+
+func init() {
+	Command.AddCommand(showCmd)
+}
@@ -1,6 +1,7 @@
 package configure
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 
@@ -61,6 +62,9 @@ var Command = &cobra.Command{
 			return err
 		}
 
+		if viper.ConfigFileUsed() == "" {
+			return errors.New("config was read from a URL, and can't be written")
+		}
 		err := viper.WriteConfigAs(viper.ConfigFileUsed())
 		if err != nil {
 			return fmt.Errorf("error writing config: %v", err)
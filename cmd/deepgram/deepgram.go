@@ -6,24 +6,84 @@ import (
 	"errors"
 	"fmt"
 	"io/fs"
+	"net/http"
 	"os"
 	"path"
+	"path/filepath"
+	"strings"
+	"text/template"
 	"time"
 
+	"github.com/deepakjois/podscript/internal/audio"
+	"github.com/deepakjois/podscript/internal/config"
+	"github.com/deepakjois/podscript/internal/download"
+	"github.com/deepakjois/podscript/internal/llm"
+	"github.com/deepakjois/podscript/internal/output"
+	"github.com/deepakjois/podscript/internal/stt"
 	prerecorded "github.com/deepgram/deepgram-go-sdk/pkg/api/prerecorded/v1"
 	api "github.com/deepgram/deepgram-go-sdk/pkg/api/prerecorded/v1/interfaces"
 	interfaces "github.com/deepgram/deepgram-go-sdk/pkg/client/interfaces"
 	client "github.com/deepgram/deepgram-go-sdk/pkg/client/prerecorded"
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
+	"github.com/tmc/langchaingo/llms"
+)
+
+// Pay-as-you-go price of the nova-2 model as of Jul 2024
+const costPerMinute = 0.0043
+
+// Output modes
+const (
+	outputParagraphs = "paragraphs" // paragraphs formatted by Deepgram
+	outputUtterances = "utterances" // speaker-labelled turns
+	outputRaw        = "raw"        // unformatted transcript text
 )
 
 func init() {
 	Command.Flags().StringP("path", "p", "", "save transcripts and API responses to path")
 	Command.Flags().StringP("suffix", "s", "", "append suffix to filenames for easier recognition")
+	Command.Flags().String("api-key", "", "API key (overrides environment and config)")
 	Command.Flags().BoolP("from-file", "f", false, "transcribe from local audio file (mutually exclusive with --from-url)")
 	Command.Flags().BoolP("from-url", "u", false, "transcribe from remote audio file (mutually exclusive with --from-file)")
+	Command.Flags().BoolP("combine", "c", false, "merge consecutive utterances from the same speaker into a single paragraph")
+	Command.Flags().Bool("infer-speakers", false, "use an LLM to infer speaker names from the transcript")
+	Command.Flags().String("infer-model", string(llm.ChatGpt4oMini), "LLM used by --infer-speakers")
+	Command.Flags().String("vocab", "", "file with terms (one per line) to bias transcription towards, for e.g. names and jargon")
+	Command.Flags().Bool("trim-silence", false, "remove long silences from local audio before transcribing (requires ffmpeg)")
+	Command.Flags().Bool("embed", false, "save a copy of the local audio file with the transcript in its metadata (requires ffmpeg)")
+	Command.Flags().StringArray("concat", nil, "local audio file to join to the end of the input before transcribing (can be repeated, requires ffmpeg)")
+	Command.Flags().Bool("download-first", false, "download the audio from --from-url and upload it, for URLs that Deepgram can't fetch (for e.g. signed or authenticated URLs)")
+	Command.Flags().StringArray("download-header", nil, "HTTP header to send when downloading with --download-first, for e.g. \"Authorization: Bearer token\" (can be repeated)")
+	Command.Flags().String("max-file-size", "", "with --download-first, stop downloading files larger than this, for e.g. 500MB")
+	Command.Flags().Duration("max-duration", 0, "with --download-first, don't transcribe audio longer than this, for e.g. 2h (requires ffmpeg)")
+	Command.Flags().Bool("estimate", false, "print the audio duration and estimated cost, without transcribing")
+	Command.Flags().String("output-mode", outputParagraphs, fmt.Sprintf("transcript text to write - one of %s, %s, %s", outputParagraphs, outputUtterances, outputRaw))
+	Command.Flags().String("format", stt.FormatText, fmt.Sprintf("output format - one of %s", strings.Join(stt.Formats, ", ")))
+	Command.Flags().Float64("min-confidence", 0, "mark words transcribed with a confidence below this (between 0 and 1) with [?]")
+	Command.Flags().Bool("confidence", false, "prefix each utterance with its confidence score")
+	Command.Flags().Bool("multilingual", false, "transcribe audio that switches between languages (code-switching)")
+	Command.Flags().StringSlice("language", nil, "comma-separated language codes to try in turn, for e.g. en,es - the audio is transcribed again in the next language if confidence is low")
+	Command.MarkFlagsMutuallyExclusive("language", "multilingual")
+	Command.Flags().String("split-by-speaker", "", fmt.Sprintf("also write a file per speaker with only their utterances - %s (the default, with the combined transcript) or %s (instead of it)", stt.SplitBoth, stt.SplitOnly))
+	Command.Flags().Lookup("split-by-speaker").NoOptDefVal = stt.SplitBoth
+	Command.Flags().String("template", "", "also write the transcript using this Go text/template, for e.g. to add front matter (see README)")
 	Command.MarkFlagsMutuallyExclusive("from-file", "from-url")
+	Command.MarkFlagsMutuallyExclusive("embed", "from-url")
+	Command.MarkFlagsMutuallyExclusive("concat", "from-url")
+	Command.MarkFlagsMutuallyExclusive("concat", "embed")
+	Command.MarkFlagsMutuallyExclusive("concat", "estimate")
+}
+
+// downloadLimits returns the limits on files downloaded with --download-first.
+func downloadLimits(cmd *cobra.Command) (download.Limits, error) {
+	var limits download.Limits
+	if size, _ := cmd.Flags().GetString("max-file-size"); size != "" {
+		var err error
+		if limits.MaxSize, err = download.ParseSize(size); err != nil {
+			return limits, fmt.Errorf("invalid --max-file-size: %w", err)
+		}
+	}
+	limits.MaxDuration, _ = cmd.Flags().GetDuration("max-duration")
+	return limits, nil
 }
 
 var Command = &cobra.Command{
@@ -40,19 +100,72 @@ var Command = &cobra.Command{
 		return nil
 	},
 	RunE: func(cmd *cobra.Command, args []string) error {
-		apiKey := viper.GetString("deepgram_api_key")
+		if estimate, _ := cmd.Flags().GetBool("estimate"); estimate {
+			audio.PrintEstimate(context.Background(), args[0], costPerMinute)
+			return nil
+		}
+
+		if apiKey, _ := cmd.Flags().GetString("api-key"); apiKey != "" {
+			config.SetAPIKey(config.Deepgram, apiKey)
+		}
+		apiKey := config.APIKey(config.Deepgram)
 		if apiKey == "" {
 			return errors.New("Deepgram API key not found. Please run 'podscript configure' or set the DEEPGRAM_API_KEY environment variable.")
 		}
 
 		folder, _ := cmd.Flags().GetString("path")
 		suffix, _ := cmd.Flags().GetString("suffix")
-		if folder != "" {
-			fi, err := os.Stat(folder)
-			if err != nil || !fi.IsDir() {
-				return fmt.Errorf("path not found: %s", folder)
+		inferSpeakers, _ := cmd.Flags().GetBool("infer-speakers")
+		inferModel, _ := cmd.Flags().GetString("infer-model")
+		// The model is initialized before the audio is transcribed, so that
+		// for e.g. a missing API key doesn't waste a paid transcription
+		var inferLLM llms.Model
+		if inferSpeakers {
+			if !llm.Supported(llm.Model(inferModel)) {
+				return fmt.Errorf("invalid model for --infer-speakers: %s", inferModel)
+			}
+			var err error
+			if inferLLM, err = llm.New(llm.Model(inferModel)); err != nil {
+				return fmt.Errorf("failed to initialize model %s: %w", inferModel, err)
+			}
+		}
+		format, _ := cmd.Flags().GetString("format")
+		if !stt.ValidFormat(format) {
+			return fmt.Errorf("invalid format: must be one of %s", strings.Join(stt.Formats, ", "))
+		}
+		combine, _ := cmd.Flags().GetBool("combine")
+		outputMode, _ := cmd.Flags().GetString("output-mode")
+		switch outputMode {
+		case outputParagraphs, outputUtterances, outputRaw:
+		default:
+			return fmt.Errorf("invalid output mode: must be one of %s, %s, %s", outputParagraphs, outputUtterances, outputRaw)
+		}
+		minConfidence, _ := cmd.Flags().GetFloat64("min-confidence")
+		showConfidence, _ := cmd.Flags().GetBool("confidence")
+		split, _ := cmd.Flags().GetString("split-by-speaker")
+		if !stt.ValidSplit(split) {
+			return fmt.Errorf("invalid --split-by-speaker: must be one of %s, %s", stt.SplitBoth, stt.SplitOnly)
+		}
+		// Combining, naming speakers, confidences, splitting and formats other
+		// than text all work on utterances
+		useUtterances := outputMode == outputUtterances || combine || inferSpeakers || format != stt.FormatText ||
+			minConfidence > 0 || showConfidence || split != ""
+		if outputMode == outputRaw && useUtterances {
+			return errors.New("--output-mode raw cannot be used with --combine, --infer-speakers, --format, --split-by-speaker or confidence options")
+		}
+
+		var tmpl *template.Template
+		templateFile, _ := cmd.Flags().GetString("template")
+		if templateFile != "" {
+			var err error
+			if tmpl, err = stt.ReadTemplate(templateFile); err != nil {
+				return err
 			}
 		}
+
+		if err := output.CheckDir(folder); err != nil {
+			return err
+		}
 		timestamp := time.Now().Format("2006-01-02-150405")
 		var filenameSuffix string
 		if suffix == "" {
@@ -69,10 +182,24 @@ var Command = &cobra.Command{
 			SmartFormat: true,
 			Punctuate:   true,
 			Diarize:     true,
-			Utterances:  true,
+			Utterances:  useUtterances || tmpl != nil,
+		}
+		if vocab, _ := cmd.Flags().GetString("vocab"); vocab != "" {
+			terms, err := stt.ReadVocabulary(vocab)
+			if err != nil {
+				return err
+			}
+			options.Keywords = terms
+		}
+		languages, _ := cmd.Flags().GetStringSlice("language")
+		if multilingual, _ := cmd.Flags().GetBool("multilingual"); multilingual {
+			// nova-2 transcribes each segment in the language it is spoken in
+			options.Language = "multi"
 		}
 
 		c := client.New(apiKey, &interfaces.ClientOptions{})
+		// Use the shared transport so that TLS settings from the root command apply
+		c.Transport = http.DefaultTransport
 		dg := prerecorded.New(c)
 
 		useFile, _ := cmd.Flags().GetBool("from-file")
@@ -83,22 +210,77 @@ var Command = &cobra.Command{
 			return errors.New("only one of --from-file or --from-url must be specified")
 		}
 
+		input := args[0]
+		if downloadFirst, _ := cmd.Flags().GetBool("download-first"); downloadFirst && useURL {
+			if !client.IsURL(input) {
+				return fmt.Errorf("could not parse URL %s", input)
+			}
+			headers, _ := cmd.Flags().GetStringArray("download-header")
+			header, err := download.ParseHeaders(headers)
+			if err != nil {
+				return err
+			}
+			limits, err := downloadLimits(cmd)
+			if err != nil {
+				return err
+			}
+			downloaded, err := download.File(ctx, input, header, limits)
+			if err != nil {
+				return err
+			}
+			defer os.Remove(downloaded)
+			fmt.Printf("downloaded %s\n", input)
+			input = downloaded
+			useFile, useURL = true, false
+		}
+
 		var (
-			res *api.PreRecordedResponse
-			err error
+			res       *api.PreRecordedResponse
+			timingMap audio.TimingMap
+			err       error
 		)
 		if useFile {
 			var fi fs.FileInfo
-			fi, err = os.Stat(args[0])
+			fi, err = os.Stat(input)
 			if err != nil || fi.IsDir() {
-				return fmt.Errorf("invalid file path or URL: %s", args[0])
+				return fmt.Errorf("invalid file path or URL: %s", input)
+			}
+			audioFile := input
+			if concat, _ := cmd.Flags().GetStringArray("concat"); len(concat) > 0 {
+				var joined string
+				joined, err = audio.Concat(ctx, append([]string{audioFile}, concat...))
+				if err != nil {
+					return fmt.Errorf("failed to concatenate audio: %w", err)
+				}
+				defer os.Remove(joined)
+				audioFile = joined
+				fmt.Printf("concatenated %d audio files\n", len(concat)+1)
+			}
+			if trimSilence, _ := cmd.Flags().GetBool("trim-silence"); trimSilence {
+				var trimmed string
+				trimmed, timingMap, err = audio.TrimSilence(ctx, audioFile)
+				if err != nil {
+					return fmt.Errorf("failed to trim silence: %w", err)
+				}
+				defer os.Remove(trimmed)
+				audioFile = trimmed
+
+				timingMapFilename := path.Join(folder, fmt.Sprintf("deepgram_timing_map_%s.json", filenameSuffix))
+				if err = timingMap.WriteFile(timingMapFilename); err != nil {
+					return fmt.Errorf("failed to write timing map: %w", err)
+				}
+				fmt.Printf("trimmed %d silences, wrote timing map to %s\n", len(timingMap.Cuts), timingMapFilename)
 			}
-			res, err = dg.FromFile(ctx, args[0], options)
+			res, err = transcribeLanguages(languages, options, func(o *interfaces.PreRecordedTranscriptionOptions) (*api.PreRecordedResponse, error) {
+				return dg.FromFile(ctx, audioFile, o)
+			})
 		} else {
 			if !client.IsURL(args[0]) {
 				return fmt.Errorf("could not parse URL %s", args[0])
 			}
-			res, err = dg.FromURL(ctx, args[0], options)
+			res, err = transcribeLanguages(languages, options, func(o *interfaces.PreRecordedTranscriptionOptions) (*api.PreRecordedResponse, error) {
+				return dg.FromURL(ctx, args[0], o)
+			})
 		}
 
 		if err != nil {
@@ -116,11 +298,96 @@ var Command = &cobra.Command{
 		}
 		fmt.Printf("wrote raw JSON API response to %s\n", jsonFilename)
 
-		transcriptFilename := path.Join(folder, fmt.Sprintf("deepgram_transcript_%s.txt", filenameSuffix))
-		if err = os.WriteFile(transcriptFilename, []byte(res.Results.Channels[0].Alternatives[0].Paragraphs.Transcript), 0644); err != nil {
-			return fmt.Errorf("failed to write transcript: %w", err)
+		transcriptFilename := path.Join(folder, fmt.Sprintf("deepgram_transcript_%s.%s", filenameSuffix, stt.Extension(format)))
+		transcript := res.Results.Channels[0].Alternatives[0].Paragraphs.Transcript
+		if outputMode == outputRaw {
+			transcript = res.Results.Channels[0].Alternatives[0].Transcript
+		}
+		var utterances []stt.Utterance
+		if useUtterances || tmpl != nil {
+			utterances = stt.MapTimes(stt.DeepgramUtterances(res), timingMap.Original)
+		}
+		text := transcript
+		if useUtterances {
+			if combine {
+				utterances = stt.Combine(utterances)
+			}
+			if inferSpeakers {
+				names, err := stt.InferSpeakerNames(ctx, inferLLM, utterances)
+				if err != nil {
+					return err
+				}
+				if confirmed, err := stt.ConfirmSpeakerNames(names); err != nil {
+					return err
+				} else if confirmed {
+					utterances = stt.NameSpeakers(utterances, names)
+				}
+			}
+
+			if minConfidence > 0 {
+				utterances = stt.MarkLowConfidence(utterances, minConfidence)
+			}
+			if showConfidence {
+				utterances = stt.AnnotateConfidence(utterances)
+			}
+
+			var sb strings.Builder
+			if err = stt.Write(&sb, format, args[0], utterances); err != nil {
+				return err
+			}
+			transcript = sb.String()
+
+			var tb strings.Builder
+			if err = stt.WriteText(&tb, utterances); err != nil {
+				return err
+			}
+			text = tb.String()
+
+			if split != "" {
+				filenames, err := stt.WriteBySpeaker(func(speaker string) string {
+					return path.Join(folder, fmt.Sprintf("deepgram_transcript_%s_%s.%s", filenameSuffix, speaker, stt.Extension(format)))
+				}, format, args[0], utterances)
+				if err != nil {
+					return err
+				}
+				for _, f := range filenames {
+					fmt.Printf("wrote speaker transcript to %s\n", f)
+				}
+			}
+		}
+		if split != stt.SplitOnly {
+			if err = output.WriteFile(transcriptFilename, []byte(transcript)); err != nil {
+				return fmt.Errorf("failed to write transcript: %w", err)
+			}
+			fmt.Printf("wrote transcript to %s\n", transcriptFilename)
+		}
+
+		if tmpl != nil {
+			data := stt.TemplateData{
+				Title:      filepath.Base(args[0]),
+				Model:      options.Model,
+				Date:       time.Now(),
+				Transcript: text,
+				Segments:   stt.SegmentsFromUtterances(utterances),
+				Utterances: utterances,
+			}
+			if useURL {
+				data.Title, data.URL = args[0], args[0]
+			}
+			templateFilename := path.Join(folder, fmt.Sprintf("deepgram_templated_transcript_%s.%s", filenameSuffix, stt.TemplateExtension(templateFile)))
+			if err = stt.WriteTemplate(templateFilename, tmpl, data); err != nil {
+				return err
+			}
+			fmt.Printf("wrote templated transcript to %s\n", templateFilename)
+		}
+
+		if embed, _ := cmd.Flags().GetBool("embed"); embed {
+			embedFilename := audio.EmbedFilename(args[0], folder, filenameSuffix)
+			if err = audio.Embed(ctx, args[0], embedFilename, transcript, nil); err != nil {
+				return fmt.Errorf("failed to embed transcript: %w", err)
+			}
+			fmt.Printf("wrote audio with embedded transcript to %s\n", embedFilename)
 		}
-		fmt.Printf("wrote transcript to %s\n", transcriptFilename)
 		return nil
 	},
 }
@@ -0,0 +1,51 @@
+package deepgram
+
+import (
+	"errors"
+	"fmt"
+
+	api "github.com/deepgram/deepgram-go-sdk/pkg/api/prerecorded/v1/interfaces"
+	interfaces "github.com/deepgram/deepgram-go-sdk/pkg/client/interfaces"
+)
+
+// minLanguageConfidence is the confidence of a transcript below which it is
+// transcribed again in the next language given with --language.
+const minLanguageConfidence = 0.7
+
+// transcribeLanguages transcribes the audio with transcribe in each of
+// languages in turn, until the confidence of the transcript is high enough,
+// and returns the most confident transcript. Without languages, the audio is
+// transcribed once with options as they are.
+func transcribeLanguages(languages []string, options *interfaces.PreRecordedTranscriptionOptions, transcribe func(*interfaces.PreRecordedTranscriptionOptions) (*api.PreRecordedResponse, error)) (*api.PreRecordedResponse, error) {
+	if len(languages) == 0 {
+		return transcribe(options)
+	}
+
+	var (
+		best           *api.PreRecordedResponse
+		bestLanguage   string
+		bestConfidence float64
+	)
+	for i, language := range languages {
+		options.Language = language
+		res, err := transcribe(options)
+		if err != nil {
+			return nil, err
+		}
+		if len(res.Results.Channels) == 0 || len(res.Results.Channels[0].Alternatives) == 0 {
+			return nil, errors.New("transcription failed: no channels in response")
+		}
+		confidence := res.Results.Channels[0].Alternatives[0].Confidence
+		if best == nil || confidence > bestConfidence {
+			best, bestLanguage, bestConfidence = res, language, confidence
+		}
+		if confidence >= minLanguageConfidence {
+			break
+		}
+		if i < len(languages)-1 {
+			fmt.Printf("confidence of the %s transcript is %.2f, transcribing again in %s…\n", language, confidence, languages[i+1])
+		}
+	}
+	fmt.Printf("transcribed in %s (confidence %.2f)\n", bestLanguage, bestConfidence)
+	return best, nil
+}
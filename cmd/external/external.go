@@ -0,0 +1,104 @@
+package external
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/deepakjois/podscript/internal/output"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func init() {
+	Command.Flags().StringP("path", "p", "", "save transcripts to path")
+	Command.Flags().StringP("suffix", "s", "", "append suffix to filenames for easier recognition")
+	Command.Flags().String("stt-command", "", "command that transcribes {input} and writes the transcript to {output} (overrides stt_command in config)")
+}
+
+// shellQuote quotes s for use as a single argument in a POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+var Command = &cobra.Command{
+	Use:   "external <audio_file>",
+	Short: "Generate transcript of an audio file using an external command.",
+	Long: `Generate transcript of an audio file using an external command, for e.g. a
+local Whisper install or a custom script.
+
+The command is run using the shell, after replacing {input} with the path to
+the audio file and {output} with the path the transcript should be written to.
+Both paths are quoted, so they should not be quoted in the command.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		sttCommand, _ := cmd.Flags().GetString("stt-command")
+		if sttCommand == "" {
+			sttCommand = viper.GetString("stt_command")
+		}
+		if sttCommand == "" {
+			return errors.New("external command not found. Please pass --stt-command or set stt_command in the config file")
+		}
+		if !strings.Contains(sttCommand, "{input}") || !strings.Contains(sttCommand, "{output}") {
+			return errors.New("external command must contain {input} and {output}")
+		}
+
+		folder, _ := cmd.Flags().GetString("path")
+		suffix, _ := cmd.Flags().GetString("suffix")
+		if err := output.CheckDir(folder); err != nil {
+			return err
+		}
+		timestamp := time.Now().Format("2006-01-02-150405")
+		var filenameSuffix string
+		if suffix == "" {
+			filenameSuffix = timestamp
+		} else {
+			filenameSuffix = fmt.Sprintf("%s_%s", timestamp, suffix)
+		}
+
+		audioFile := filepath.Clean(args[0])
+		if fi, err := os.Stat(audioFile); err != nil || fi.IsDir() {
+			return fmt.Errorf("invalid audio file: %s", audioFile)
+		}
+
+		f, err := os.CreateTemp("", "podscript-*.txt")
+		if err != nil {
+			return fmt.Errorf("failed to create temporary file: %w", err)
+		}
+		outputFile := f.Name()
+		f.Close()
+		defer os.Remove(outputFile)
+
+		shellCmd := strings.NewReplacer(
+			"{input}", shellQuote(audioFile),
+			"{output}", shellQuote(outputFile),
+		).Replace(sttCommand)
+
+		c := exec.CommandContext(cmd.Context(), "sh", "-c", shellCmd)
+		c.Stdout = os.Stdout
+		c.Stderr = os.Stderr
+		if err = c.Run(); err != nil {
+			return fmt.Errorf("external command failed: %w", err)
+		}
+
+		transcript, err := os.ReadFile(outputFile)
+		if err != nil {
+			return fmt.Errorf("failed to read transcript: %w", err)
+		}
+		if len(strings.TrimSpace(string(transcript))) == 0 {
+			return errors.New("external command did not write a transcript")
+		}
+
+		transcriptFilename := path.Join(folder, fmt.Sprintf("external_transcript_%s.txt", filenameSuffix))
+		if err = output.WriteFile(transcriptFilename, transcript); err != nil {
+			return fmt.Errorf("failed to write transcript: %w", err)
+		}
+		fmt.Printf("wrote transcript to %s\n", transcriptFilename)
+		return nil
+	},
+}
@@ -1,108 +1,41 @@
 package groq
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
-	"mime/multipart"
-	"net/http"
 	"os"
 	"path"
-	"path/filepath"
+	"strings"
 	"time"
 
+	"github.com/deepakjois/podscript/internal/audio"
+	"github.com/deepakjois/podscript/internal/config"
+	"github.com/deepakjois/podscript/internal/output"
+	"github.com/deepakjois/podscript/internal/stt"
+	"github.com/deepakjois/podscript/internal/whisper"
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
 )
 
 const (
 	apiURL      = "https://api.groq.com/openai/v1/audio/translations"
 	maxFileSize = 25 * 1024 * 1024 // 25MB in bytes
+
+	// Price of whisper-large-v3 as of Jul 2024 ($0.111 per hour)
+	costPerMinute = 0.111 / 60
 )
 
 func init() {
 	Command.Flags().StringP("path", "p", "", "save transcripts and API responses to path")
 	Command.Flags().StringP("suffix", "s", "", "append suffix to filenames for easier recognition")
+	Command.Flags().String("api-key", "", "API key (overrides environment and config)")
 	Command.Flags().BoolP("verbose", "v", false, "fetch verbose JSON response (includes token and start/end timestamps)")
-}
-
-type WhisperRequest struct {
-	FilePath       string
-	Model          string
-	Prompt         string
-	Temperature    float64
-	ResponseFormat string
-	APIKey         string
-}
-
-type WhisperResponse struct {
-	Text string `json:"text"`
-}
-
-func makeWhisperAPICall(req WhisperRequest) ([]byte, error) {
-	// Create a buffer to store the multipart form data
-	var requestBody bytes.Buffer
-	writer := multipart.NewWriter(&requestBody)
-
-	// Add the file to the form
-	file, err := os.Open(req.FilePath)
-	if err != nil {
-		return nil, fmt.Errorf("error opening file: %w", err)
-	}
-	defer file.Close()
-
-	part, err := writer.CreateFormFile("file", filepath.Base(req.FilePath))
-	if err != nil {
-		return nil, fmt.Errorf("error creating form file: %w", err)
-	}
-	_, err = io.Copy(part, file)
-	if err != nil {
-		return nil, fmt.Errorf("error copying file content: %w", err)
-	}
-
-	// Add other form fields
-	writer.WriteField("model", req.Model)
-	writer.WriteField("prompt", req.Prompt)
-	writer.WriteField("temperature", fmt.Sprintf("%f", req.Temperature))
-	writer.WriteField("response_format", req.ResponseFormat)
-
-	// Close the multipart writer
-	err = writer.Close()
-	if err != nil {
-		return nil, fmt.Errorf("error closing multipart writer: %w", err)
-	}
-
-	// Create the HTTP request
-	httpReq, err := http.NewRequest("POST", apiURL, &requestBody)
-	if err != nil {
-		return nil, fmt.Errorf("error creating request: %w", err)
-	}
-
-	// Set headers
-	httpReq.Header.Set("Authorization", "Bearer "+req.APIKey)
-	httpReq.Header.Set("Content-Type", writer.FormDataContentType())
-
-	// Make the request
-	client := &http.Client{}
-	resp, err := client.Do(httpReq)
-	if err != nil {
-		return nil, fmt.Errorf("error making request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	// Read the response
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("error reading response: %w", err)
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API request failed with status code %d: %s", resp.StatusCode, string(body))
-	}
-
-	return body, nil
+	Command.Flags().String("vocab", "", "file with terms (one per line) to bias transcription towards, for e.g. names and jargon")
+	Command.Flags().Bool("trim-silence", false, "remove long silences from local audio before transcribing (requires ffmpeg)")
+	Command.Flags().Bool("embed", false, "save a copy of the local audio file with the transcript in its metadata (requires ffmpeg)")
+	Command.Flags().Bool("estimate", false, "print the audio duration and estimated cost, without transcribing")
+	Command.Flags().String("subtitles", "", fmt.Sprintf("also write subtitles from the segment timestamps - one of %s, %s", stt.SubtitlesSRT, stt.SubtitlesVTT))
 }
 
 var Command = &cobra.Command{
@@ -110,18 +43,23 @@ var Command = &cobra.Command{
 	Short: "Generate transcript of an audio file using Groq's Whisper API.",
 	Args:  cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		apiKey := viper.GetString("groq_api_key")
+		if estimate, _ := cmd.Flags().GetBool("estimate"); estimate {
+			audio.PrintEstimate(context.Background(), args[0], costPerMinute)
+			return nil
+		}
+
+		if apiKey, _ := cmd.Flags().GetString("api-key"); apiKey != "" {
+			config.SetAPIKey(config.Groq, apiKey)
+		}
+		apiKey := config.APIKey(config.Groq)
 		if apiKey == "" {
 			return errors.New("Groq API key not found. Please run 'podscript configure' or set the GROQ_API_KEY environment variable")
 		}
 
 		folder, _ := cmd.Flags().GetString("path")
 		suffix, _ := cmd.Flags().GetString("suffix")
-		if folder != "" {
-			fi, err := os.Stat(folder)
-			if err != nil || !fi.IsDir() {
-				return fmt.Errorf("path not found: %s", folder)
-			}
+		if err := output.CheckDir(folder); err != nil {
+			return err
 		}
 		timestamp := time.Now().Format("2006-01-02-150405")
 		var filenameSuffix string
@@ -131,31 +69,73 @@ var Command = &cobra.Command{
 			filenameSuffix = fmt.Sprintf("%s_%s", timestamp, suffix)
 		}
 
-		fi, err := os.Stat(args[0])
+		subtitles, _ := cmd.Flags().GetString("subtitles")
+		if !stt.ValidSubtitles(subtitles) {
+			return fmt.Errorf("invalid subtitle format: must be one of %s, %s", stt.SubtitlesSRT, stt.SubtitlesVTT)
+		}
+
+		audioFile := args[0]
+		fi, err := os.Stat(audioFile)
 		if err != nil || fi.IsDir() {
 			return fmt.Errorf("invalid audio file: %s", folder)
 		}
 
+		var timingMap audio.TimingMap
+		if trimSilence, _ := cmd.Flags().GetBool("trim-silence"); trimSilence {
+			var trimmed string
+			trimmed, timingMap, err = audio.TrimSilence(context.Background(), audioFile)
+			if err != nil {
+				return fmt.Errorf("failed to trim silence: %w", err)
+			}
+			defer os.Remove(trimmed)
+			audioFile = trimmed
+
+			timingMapFilename := path.Join(folder, fmt.Sprintf("groq_whisper_api_timing_map_%s.json", filenameSuffix))
+			if err = timingMap.WriteFile(timingMapFilename); err != nil {
+				return fmt.Errorf("failed to write timing map: %w", err)
+			}
+			fmt.Printf("trimmed %d silences, wrote timing map to %s\n", len(timingMap.Cuts), timingMapFilename)
+
+			// Check the size of the trimmed audio against the limit
+			if fi, err = os.Stat(audioFile); err != nil {
+				return fmt.Errorf("invalid audio file: %s", audioFile)
+			}
+		}
+
 		if fi.Size() > maxFileSize {
 			return fmt.Errorf("file size exceeds 25MB")
 		}
 
 		var format string
-		if verbose, _ := cmd.Flags().GetBool("verbose"); verbose {
+		if verbose, _ := cmd.Flags().GetBool("verbose"); verbose || subtitles != "" {
+			// Segment timestamps are only included in verbose responses
 			format = "verbose_json"
 		} else {
 			format = "json"
 		}
-		request := WhisperRequest{
-			FilePath:       args[0],
+
+		// Whisper has no dedicated option for custom vocabulary, but terms that
+		// appear in the prompt are more likely to be transcribed correctly.
+		var prompt string
+		if vocab, _ := cmd.Flags().GetString("vocab"); vocab != "" {
+			terms, err := stt.ReadVocabulary(vocab)
+			if err != nil {
+				return err
+			}
+			prompt = strings.Join(terms, ", ")
+		}
+
+		request := whisper.Request{
+			URL:            apiURL,
+			FilePath:       audioFile,
 			Model:          "whisper-large-v3",
-			Prompt:         "",
+			Prompt:         prompt,
 			Temperature:    0,
 			ResponseFormat: format,
 			APIKey:         apiKey,
 		}
 
-		data, err := makeWhisperAPICall(request)
+		data, err := whisper.Transcribe(request)
 		if err != nil {
 			return err
 		}
@@ -166,16 +146,42 @@ var Command = &cobra.Command{
 		}
 		fmt.Printf("wrote raw JSON API response to %s\n", jsonFilename)
 
-		var whisperResp WhisperResponse
+		var whisperResp whisper.Response
 		if err := json.Unmarshal(data, &whisperResp); err != nil {
 			return fmt.Errorf("json parsing failed: %w", err)
 		}
 
 		transcriptFilename := path.Join(folder, fmt.Sprintf("groq_whisper_api_transcript_%s.txt", filenameSuffix))
-		if err = os.WriteFile(transcriptFilename, []byte(whisperResp.Text), 0644); err != nil {
+		if err = output.WriteFile(transcriptFilename, []byte(whisperResp.Text)); err != nil {
 			return fmt.Errorf("failed to write transcript: %w", err)
 		}
 		fmt.Printf("wrote transcript to %s\n", transcriptFilename)
+
+		if subtitles != "" {
+			segments := whisperResp.Segments
+			if len(timingMap.Cuts) > 0 {
+				for i := range segments {
+					segments[i].Start, segments[i].End = timingMap.Original(segments[i].Start), timingMap.Original(segments[i].End)
+				}
+			}
+			subtitlesFilename := path.Join(folder, fmt.Sprintf("groq_whisper_api_subtitles_%s.%s", filenameSuffix, subtitles))
+			var sb strings.Builder
+			if err = stt.WriteSubtitles(&sb, subtitles, segments); err != nil {
+				return err
+			}
+			if err = output.WriteFile(subtitlesFilename, []byte(sb.String())); err != nil {
+				return fmt.Errorf("failed to write subtitles: %w", err)
+			}
+			fmt.Printf("wrote subtitles to %s\n", subtitlesFilename)
+		}
+
+		if embed, _ := cmd.Flags().GetBool("embed"); embed {
+			embedFilename := audio.EmbedFilename(args[0], folder, filenameSuffix)
+			if err = audio.Embed(context.Background(), args[0], embedFilename, whisperResp.Text, nil); err != nil {
+				return fmt.Errorf("failed to embed transcript: %w", err)
+			}
+			fmt.Printf("wrote audio with embedded transcript to %s\n", embedFilename)
+		}
 		return nil
 	},
 }
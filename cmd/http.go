@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/deepakjois/podscript/internal/download"
+)
+
+// headerTransport adds headers to every request sent through base.
+type headerTransport struct {
+	base   http.RoundTripper
+	header http.Header
+}
+
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// RoundTrippers must not modify the request they are given
+	req = req.Clone(req.Context())
+	for name, values := range t.header {
+		// Headers set by the provider's client, such as its API key, take
+		// precedence
+		if req.Header.Get(name) != "" {
+			continue
+		}
+		for _, v := range values {
+			req.Header.Add(name, v)
+		}
+	}
+	return t.base.RoundTrip(req)
+}
+
+// configureHTTPHeaders wraps http.DefaultTransport, so that headers are sent
+// with every outbound request, for e.g. tracing headers or API gateway tokens.
+// It must be called after configureHTTPTransport.
+func configureHTTPHeaders(headers []string) error {
+	if len(headers) == 0 {
+		return nil
+	}
+	header, err := download.ParseHeaders(headers)
+	if err != nil {
+		return err
+	}
+	http.DefaultTransport = &headerTransport{base: http.DefaultTransport, header: header}
+	return nil
+}
+
+// configureHTTPTransport applies TLS settings to http.DefaultTransport, which
+// is shared by the HTTP clients of all the providers.
+func configureHTTPTransport(caCert string) error {
+	if caCert == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(caCert)
+	if err != nil {
+		return fmt.Errorf("failed to read CA certificate: %w", err)
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(data) {
+		return fmt.Errorf("no valid PEM certificates found in %s", caCert)
+	}
+
+	transport, ok := http.DefaultTransport.(*http.Transport)
+	if !ok {
+		return fmt.Errorf("unexpected default HTTP transport type %T", http.DefaultTransport)
+	}
+	transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	return nil
+}
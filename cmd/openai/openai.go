@@ -0,0 +1,188 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/deepakjois/podscript/internal/audio"
+	"github.com/deepakjois/podscript/internal/config"
+	"github.com/deepakjois/podscript/internal/output"
+	"github.com/deepakjois/podscript/internal/stt"
+	"github.com/deepakjois/podscript/internal/whisper"
+	"github.com/spf13/cobra"
+)
+
+const (
+	apiURL      = "https://api.openai.com/v1/audio/transcriptions"
+	maxFileSize = 25 * 1024 * 1024 // 25MB in bytes
+)
+
+// Models
+const (
+	whisper1            = "whisper-1"
+	gpt4oTranscribe     = "gpt-4o-transcribe"
+	gpt4oMiniTranscribe = "gpt-4o-mini-transcribe"
+)
+
+// Prices per minute as of Mar 2025
+var costPerMinute = map[string]float64{
+	whisper1:            0.006,
+	gpt4oTranscribe:     0.006,
+	gpt4oMiniTranscribe: 0.003,
+}
+
+func init() {
+	Command.Flags().StringP("path", "p", "", "save transcripts and API responses to path")
+	Command.Flags().StringP("suffix", "s", "", "append suffix to filenames for easier recognition")
+	Command.Flags().String("api-key", "", "API key (overrides environment and config)")
+	Command.Flags().StringP("model", "m", gpt4oTranscribe, fmt.Sprintf("use model - one of %s, %s, %s", gpt4oTranscribe, gpt4oMiniTranscribe, whisper1))
+	Command.Flags().Bool("stream", false, "display the transcript as it is generated (not supported by whisper-1)")
+	Command.Flags().String("vocab", "", "file with terms (one per line) to bias transcription towards, for e.g. names and jargon")
+	Command.Flags().Bool("estimate", false, "print the audio duration and estimated cost, without transcribing")
+	Command.Flags().String("subtitles", "", fmt.Sprintf("also write subtitles from the segment timestamps - one of %s, %s (only supported by whisper-1)", stt.SubtitlesSRT, stt.SubtitlesVTT))
+	Command.MarkFlagsMutuallyExclusive("stream", "subtitles")
+}
+
+var Command = &cobra.Command{
+	Use:   "openai <audio_file>",
+	Short: "Generate transcript of an audio file using OpenAI's transcription API.",
+	Args:  cobra.ExactArgs(1),
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		model, _ := cmd.Flags().GetString("model")
+		if _, ok := costPerMinute[model]; !ok {
+			return fmt.Errorf("invalid model: must be one of %s, %s, %s", gpt4oTranscribe, gpt4oMiniTranscribe, whisper1)
+		}
+		if stream, _ := cmd.Flags().GetBool("stream"); stream && model == whisper1 {
+			return errors.New("--stream is not supported by whisper-1")
+		}
+		subtitles, _ := cmd.Flags().GetString("subtitles")
+		if !stt.ValidSubtitles(subtitles) {
+			return fmt.Errorf("invalid subtitle format: must be one of %s, %s", stt.SubtitlesSRT, stt.SubtitlesVTT)
+		}
+		if subtitles != "" && model != whisper1 {
+			// The gpt-4o models only return the text of the transcript
+			return errors.New("--subtitles is only supported by whisper-1")
+		}
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		model, _ := cmd.Flags().GetString("model")
+		if estimate, _ := cmd.Flags().GetBool("estimate"); estimate {
+			audio.PrintEstimate(context.Background(), args[0], costPerMinute[model])
+			return nil
+		}
+
+		if apiKey, _ := cmd.Flags().GetString("api-key"); apiKey != "" {
+			config.SetAPIKey(config.OpenAI, apiKey)
+		}
+		apiKey := config.APIKey(config.OpenAI)
+		if apiKey == "" {
+			return errors.New("OpenAI API key not found. Please run 'podscript configure' or set the OPENAI_API_KEY environment variable")
+		}
+
+		folder, _ := cmd.Flags().GetString("path")
+		suffix, _ := cmd.Flags().GetString("suffix")
+		if err := output.CheckDir(folder); err != nil {
+			return err
+		}
+		timestamp := time.Now().Format("2006-01-02-150405")
+		var filenameSuffix string
+		if suffix == "" {
+			filenameSuffix = timestamp
+		} else {
+			filenameSuffix = fmt.Sprintf("%s_%s", timestamp, suffix)
+		}
+
+		audioFile := args[0]
+		fi, err := os.Stat(audioFile)
+		if err != nil || fi.IsDir() {
+			return fmt.Errorf("invalid audio file: %s", audioFile)
+		}
+		if fi.Size() > maxFileSize {
+			return fmt.Errorf("file size exceeds 25MB")
+		}
+
+		// Terms that appear in the prompt are more likely to be transcribed
+		// correctly
+		var prompt string
+		if vocab, _ := cmd.Flags().GetString("vocab"); vocab != "" {
+			terms, err := stt.ReadVocabulary(vocab)
+			if err != nil {
+				return err
+			}
+			prompt = strings.Join(terms, ", ")
+		}
+
+		subtitles, _ := cmd.Flags().GetString("subtitles")
+		format := "json"
+		if subtitles != "" {
+			// Segment timestamps are only included in verbose responses
+			format = "verbose_json"
+		}
+
+		request := whisper.Request{
+			URL:            apiURL,
+			FilePath:       audioFile,
+			Model:          model,
+			Prompt:         prompt,
+			ResponseFormat: format,
+			APIKey:         apiKey,
+		}
+
+		var (
+			transcript string
+			segments   []stt.Segment
+		)
+		if stream, _ := cmd.Flags().GetBool("stream"); stream {
+			transcript, err = whisper.TranscribeStream(request, func(delta string) {
+				fmt.Print(delta)
+			})
+			fmt.Println()
+			if err != nil {
+				return err
+			}
+		} else {
+			data, err := whisper.Transcribe(request)
+			if err != nil {
+				return err
+			}
+
+			jsonFilename := path.Join(folder, fmt.Sprintf("openai_api_response_%s.json", filenameSuffix))
+			if err = os.WriteFile(jsonFilename, data, 0644); err != nil {
+				return fmt.Errorf("failed to write JSON response: %w", err)
+			}
+			fmt.Printf("wrote raw JSON API response to %s\n", jsonFilename)
+
+			var resp whisper.Response
+			if err := json.Unmarshal(data, &resp); err != nil {
+				return fmt.Errorf("json parsing failed: %w", err)
+			}
+			transcript, segments = resp.Text, resp.Segments
+		}
+
+		transcriptFilename := path.Join(folder, fmt.Sprintf("openai_transcript_%s.txt", filenameSuffix))
+		if err = output.WriteFile(transcriptFilename, []byte(transcript)); err != nil {
+			return fmt.Errorf("failed to write transcript: %w", err)
+		}
+		fmt.Printf("wrote transcript to %s\n", transcriptFilename)
+
+		if subtitles != "" {
+			subtitlesFilename := path.Join(folder, fmt.Sprintf("openai_subtitles_%s.%s", filenameSuffix, subtitles))
+			var sb strings.Builder
+			if err = stt.WriteSubtitles(&sb, subtitles, segments); err != nil {
+				return err
+			}
+			if err = output.WriteFile(subtitlesFilename, []byte(sb.String())); err != nil {
+				return fmt.Errorf("failed to write subtitles: %w", err)
+			}
+			fmt.Printf("wrote subtitles to %s\n", subtitlesFilename)
+		}
+		return nil
+	},
+}
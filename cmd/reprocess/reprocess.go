@@ -0,0 +1,196 @@
+package reprocess
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/deepakjois/podscript/internal/audio"
+	"github.com/deepakjois/podscript/internal/llm"
+	"github.com/deepakjois/podscript/internal/output"
+	"github.com/deepakjois/podscript/internal/stt"
+	api "github.com/deepgram/deepgram-go-sdk/pkg/api/prerecorded/v1/interfaces"
+	"github.com/spf13/cobra"
+
+	aai "github.com/AssemblyAI/assemblyai-go-sdk"
+)
+
+func init() {
+	Command.Flags().StringP("path", "p", "", "save transcripts to path")
+	Command.Flags().StringP("suffix", "s", "", "append suffix to filenames for easier recognition")
+	Command.Flags().String("format", stt.FormatText, fmt.Sprintf("output format - one of %s", strings.Join(stt.Formats, ", ")))
+	Command.Flags().BoolP("combine", "c", false, "merge consecutive utterances from the same speaker into a single paragraph")
+	Command.Flags().StringToString("speaker", nil, "name a speaker, for e.g. --speaker A=Alice (can be repeated)")
+	Command.Flags().Bool("infer-speakers", false, "use an LLM to infer speaker names from the transcript")
+	Command.Flags().String("infer-model", string(llm.ChatGpt4oMini), "LLM used by --infer-speakers")
+	Command.Flags().Float64("min-confidence", 0, "mark words transcribed with a confidence below this (between 0 and 1) with [?]")
+	Command.Flags().Bool("confidence", false, "prefix each utterance with its confidence score")
+	Command.Flags().String("split-by-speaker", "", fmt.Sprintf("also write a file per speaker with only their utterances - %s (the default, with the combined transcript) or %s (instead of it)", stt.SplitBoth, stt.SplitOnly))
+	Command.Flags().Lookup("split-by-speaker").NoOptDefVal = stt.SplitBoth
+	Command.Flags().String("subtitles", "", fmt.Sprintf("also write subtitles from the word timings - one of %s, %s", stt.SubtitlesSRT, stt.SubtitlesVTT))
+	Command.Flags().String("timing-map", "", "timing map saved with --trim-silence, to map times back to the original audio")
+	Command.MarkFlagsMutuallyExclusive("speaker", "infer-speakers")
+}
+
+// readResponse reads the utterances from a saved Deepgram or AssemblyAI API
+// response, and returns them along with the name of the provider.
+func readResponse(filename string) (string, []stt.Utterance, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to read API response: %w", err)
+	}
+
+	var fields map[string]json.RawMessage
+	if err = json.Unmarshal(data, &fields); err != nil {
+		return "", nil, fmt.Errorf("json parsing failed: %w", err)
+	}
+
+	switch {
+	case fields["results"] != nil:
+		var res api.PreRecordedResponse
+		if err = json.Unmarshal(data, &res); err != nil {
+			return "", nil, fmt.Errorf("json parsing failed: %w", err)
+		}
+		if res.Results == nil || len(res.Results.Utterances) == 0 {
+			return "", nil, errors.New("Deepgram response has no utterances, transcribe again with --output-mode utterances")
+		}
+		return "deepgram", stt.DeepgramUtterances(&res), nil
+	case fields["utterances"] != nil:
+		var transcript aai.Transcript
+		if err = json.Unmarshal(data, &transcript); err != nil {
+			return "", nil, fmt.Errorf("json parsing failed: %w", err)
+		}
+		return "assemblyai", stt.AssemblyAIUtterances(&transcript), nil
+	default:
+		return "", nil, errors.New("not a Deepgram or AssemblyAI API response with utterances")
+	}
+}
+
+var Command = &cobra.Command{
+	Use:   "reprocess <api_response.json>",
+	Short: "Generate transcript from a saved Deepgram or AssemblyAI API response.",
+	Long: `Generate transcript from a saved Deepgram or AssemblyAI API response, for e.g.
+to use a different format or name the speakers, without calling the API again.
+
+LLM cleanup is not re-run: Deepgram and AssemblyAI transcripts are already
+punctuated and split by speaker, and the cleanup prompt of ytt is written for
+unpunctuated YouTube captions, so it would merge or drop the speaker turns.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		folder, _ := cmd.Flags().GetString("path")
+		suffix, _ := cmd.Flags().GetString("suffix")
+		format, _ := cmd.Flags().GetString("format")
+		if !stt.ValidFormat(format) {
+			return fmt.Errorf("invalid format: must be one of %s", strings.Join(stt.Formats, ", "))
+		}
+		split, _ := cmd.Flags().GetString("split-by-speaker")
+		if !stt.ValidSplit(split) {
+			return fmt.Errorf("invalid --split-by-speaker: must be one of %s, %s", stt.SplitBoth, stt.SplitOnly)
+		}
+		inferSpeakers, _ := cmd.Flags().GetBool("infer-speakers")
+		inferModel, _ := cmd.Flags().GetString("infer-model")
+		if inferSpeakers && !llm.Supported(llm.Model(inferModel)) {
+			return fmt.Errorf("invalid model for --infer-speakers: %s", inferModel)
+		}
+
+		subtitles, _ := cmd.Flags().GetString("subtitles")
+		if !stt.ValidSubtitles(subtitles) {
+			return fmt.Errorf("invalid subtitle format: must be one of %s, %s", stt.SubtitlesSRT, stt.SubtitlesVTT)
+		}
+
+		if err := output.CheckDir(folder); err != nil {
+			return err
+		}
+		timestamp := time.Now().Format("2006-01-02-150405")
+		var filenameSuffix string
+		if suffix == "" {
+			filenameSuffix = timestamp
+		} else {
+			filenameSuffix = fmt.Sprintf("%s_%s", timestamp, suffix)
+		}
+
+		provider, utterances, err := readResponse(args[0])
+		if err != nil {
+			return err
+		}
+		if timingMapFile, _ := cmd.Flags().GetString("timing-map"); timingMapFile != "" {
+			timingMap, err := audio.ReadTimingMap(timingMapFile)
+			if err != nil {
+				return err
+			}
+			utterances = stt.MapTimes(utterances, timingMap.Original)
+		}
+
+		if combine, _ := cmd.Flags().GetBool("combine"); combine {
+			utterances = stt.Combine(utterances)
+		}
+		if names, _ := cmd.Flags().GetStringToString("speaker"); len(names) > 0 {
+			utterances = stt.NameSpeakers(utterances, names)
+		}
+		if inferSpeakers {
+			m, err := llm.New(llm.Model(inferModel))
+			if err != nil {
+				return fmt.Errorf("failed to initialize model %s: %w", inferModel, err)
+			}
+			names, err := stt.InferSpeakerNames(context.Background(), m, utterances)
+			if err != nil {
+				return err
+			}
+			if confirmed, err := stt.ConfirmSpeakerNames(names); err != nil {
+				return err
+			} else if confirmed {
+				utterances = stt.NameSpeakers(utterances, names)
+			}
+		}
+		if minConfidence, _ := cmd.Flags().GetFloat64("min-confidence"); minConfidence > 0 {
+			utterances = stt.MarkLowConfidence(utterances, minConfidence)
+		}
+		if showConfidence, _ := cmd.Flags().GetBool("confidence"); showConfidence {
+			utterances = stt.AnnotateConfidence(utterances)
+		}
+
+		if subtitles != "" {
+			subtitlesFilename := path.Join(folder, fmt.Sprintf("%s_subtitles_%s.%s", provider, filenameSuffix, subtitles))
+			var sb strings.Builder
+			if err = stt.WriteSubtitles(&sb, subtitles, stt.Cues(utterances)); err != nil {
+				return err
+			}
+			if err = output.WriteFile(subtitlesFilename, []byte(sb.String())); err != nil {
+				return fmt.Errorf("failed to write subtitles: %w", err)
+			}
+			fmt.Printf("wrote subtitles to %s\n", subtitlesFilename)
+		}
+
+		if split != "" {
+			filenames, err := stt.WriteBySpeaker(func(speaker string) string {
+				return path.Join(folder, fmt.Sprintf("%s_transcript_%s_%s.%s", provider, filenameSuffix, speaker, stt.Extension(format)))
+			}, format, args[0], utterances)
+			if err != nil {
+				return err
+			}
+			for _, f := range filenames {
+				fmt.Printf("wrote speaker transcript to %s\n", f)
+			}
+			if split == stt.SplitOnly {
+				return nil
+			}
+		}
+
+		transcriptFilename := path.Join(folder, fmt.Sprintf("%s_transcript_%s.%s", provider, filenameSuffix, stt.Extension(format)))
+		f, err := output.Create(transcriptFilename)
+		if err != nil {
+			return fmt.Errorf("failed to create transcript file: %w", err)
+		}
+		defer f.Close()
+		if err = stt.Write(f, format, args[0], utterances); err != nil {
+			return err
+		}
+		fmt.Printf("wrote transcript to %s\n", transcriptFilename)
+		return nil
+	},
+}
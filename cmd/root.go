@@ -6,12 +6,23 @@ import (
 	"os"
 	"path"
 
+	"github.com/charmbracelet/lipgloss"
+	"github.com/deepakjois/podscript/cmd/assemblyai"
+	"github.com/deepakjois/podscript/cmd/batch"
+	configcmd "github.com/deepakjois/podscript/cmd/config"
 	"github.com/deepakjois/podscript/cmd/configure"
 	"github.com/deepakjois/podscript/cmd/deepgram"
+	"github.com/deepakjois/podscript/cmd/external"
 	"github.com/deepakjois/podscript/cmd/groq"
+	"github.com/deepakjois/podscript/cmd/openai"
+	"github.com/deepakjois/podscript/cmd/reprocess"
+	"github.com/deepakjois/podscript/cmd/tokens"
 	"github.com/deepakjois/podscript/cmd/ytt"
-	"github.com/deepakjois/podscript/cmd/assemblyai"
+	"github.com/deepakjois/podscript/internal/config"
+	"github.com/deepakjois/podscript/internal/output"
+	"github.com/muesli/termenv"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 )
 
@@ -20,39 +31,118 @@ var rootCmd = &cobra.Command{
 	Short: "podscript generates podcast audio transcripts",
 	Long: `A tool to generate transcripts for podcast audio files using LLM and
 Speech-To-Text (STT) APIs.`,
-}
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := configureHTTP(cmd.Flags()); err != nil {
+			return err
+		}
+		footer, _ := cmd.Flags().GetString("footer")
+		if footerFile, _ := cmd.Flags().GetString("footer-file"); footerFile != "" {
+			data, err := os.ReadFile(footerFile)
+			if err != nil {
+				return fmt.Errorf("failed to read footer: %w", err)
+			}
+			footer = string(data)
+		}
+		output.SetFooter(footer)
+
+		// Styles already fall back to plain text if NO_COLOR is set or the
+		// output is not a terminal
+		if noColor, _ := cmd.Flags().GetBool("no-color"); noColor {
+			lipgloss.SetColorProfile(termenv.Ascii)
+		}
 
-var supportedLLMKeys = []string{
-	"openai_api_key",
-	"anthropic_api_key",
-	"groq_api_key",
-	"assemblyai_api_key",
+		wrap, _ := cmd.Flags().GetInt("wrap")
+		if err := output.SetWrap(wrap); err != nil {
+			return err
+		}
+
+		encoding, _ := cmd.Flags().GetString("output-encoding")
+		return output.SetEncoding(encoding)
+	},
 }
 
 func init() {
 	cobra.OnInitialize(initConfig)
 
+	rootCmd.PersistentFlags().String("config", "", "config file to use instead of $HOME/.podscript.toml - a path or an http(s):// URL")
+	rootCmd.PersistentFlags().String("output-encoding", output.EncodingUTF8, fmt.Sprintf("encoding of transcript files - one of %s, %s", output.EncodingUTF8, output.EncodingUTF8BOM))
+	rootCmd.PersistentFlags().String("footer", "", "text to append to transcripts, for e.g. an attribution or license")
+	rootCmd.PersistentFlags().String("footer-file", "", "file with text to append to transcripts")
+	rootCmd.PersistentFlags().Bool("no-color", false, "do not use colors in prompts (also set by the NO_COLOR environment variable)")
+	rootCmd.PersistentFlags().Int("wrap", 0, "wrap lines of plain text transcripts at this many columns (0 to not wrap)")
+	rootCmd.MarkFlagsMutuallyExclusive("footer", "footer-file")
+	rootCmd.PersistentFlags().String("ca-cert", "", "PEM file with additional root CA certificates to trust for outbound HTTPS requests")
+	rootCmd.PersistentFlags().StringArray("header", nil, "HTTP header to send with every outbound request, for e.g. \"X-Trace-Id: abc\" (can be repeated)")
+
 	rootCmd.AddCommand(configure.Command)
+	rootCmd.AddCommand(configcmd.Command)
 	rootCmd.AddCommand(ytt.Command)
 	rootCmd.AddCommand(deepgram.Command)
 	rootCmd.AddCommand(groq.Command)
+	rootCmd.AddCommand(openai.Command)
 	rootCmd.AddCommand(assemblyai.Command)
+	rootCmd.AddCommand(external.Command)
+	rootCmd.AddCommand(reprocess.Command)
+	rootCmd.AddCommand(batch.Command)
+	rootCmd.AddCommand(tokens.Command)
 	rootCmd.CompletionOptions.DisableDefaultCmd = true
 	rootCmd.SilenceUsage = true
 }
 
-func initConfig() {
-	homeDir, err := os.UserHomeDir()
-	cobra.CheckErr(err)
+// httpConfigured is set once the HTTP transport has been configured, as it
+// can only be configured once.
+var httpConfigured bool
 
-	viper.SetConfigType("toml")
-	viper.SetConfigFile(path.Join(homeDir, ".podscript.toml"))
+// configureHTTP applies the --ca-cert and --header flags to the HTTP
+// transport shared by all requests. Without --ca-cert, ca_cert is read from
+// the environment or the config.
+func configureHTTP(flags *pflag.FlagSet) error {
+	if httpConfigured {
+		return nil
+	}
+	httpConfigured = true
 
+	caCert, _ := flags.GetString("ca-cert")
+	if caCert == "" {
+		caCert = viper.GetString("ca_cert")
+	}
+	if err := configureHTTPTransport(caCert); err != nil {
+		return err
+	}
+	headers, _ := flags.GetStringArray("header")
+	return configureHTTPHeaders(headers)
+}
+
+func initConfig() {
 	// Bind env values to keys
-	for _, k := range supportedLLMKeys {
+	for _, k := range config.EnvKeys {
 		viper.BindEnv(k)
 	}
 
+	// A config passed with --config must be readable, except for a local file
+	// that doesn't exist yet, which can be created with 'podscript configure'
+	if location, _ := rootCmd.PersistentFlags().GetString("config"); location != "" {
+		if config.IsRemote(location) {
+			// The config is fetched using the TLS settings and headers of
+			// the flags and environment, as it can't set them itself
+			cobra.CheckErr(configureHTTP(rootCmd.PersistentFlags()))
+		}
+		if err := config.Read(location); err != nil && !errors.Is(err, os.ErrNotExist) {
+			cobra.CheckErr(err)
+		}
+		return
+	}
+
+	// Without a home directory (for e.g. in a container), only environment
+	// variables are used
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return
+	}
+
+	viper.SetConfigType("toml")
+	viper.SetConfigFile(path.Join(homeDir, ".podscript.toml"))
+
 	// Read in config file and ENV variables if set
 	if err := viper.ReadInConfig(); err != nil {
 		if !errors.Is(err, os.ErrNotExist) {
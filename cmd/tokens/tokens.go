@@ -0,0 +1,72 @@
+package tokens
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/deepakjois/podscript/internal/llm"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	Command.Flags().StringP("model", "m", string(llm.ChatGpt4oMini), "model whose tokenizer and output limit are used")
+}
+
+var Command = &cobra.Command{
+	Use:   "count-tokens [file]",
+	Short: "Count the tokens in a text and the parts ytt would split it into",
+	Long: `Count the tokens in a text for a model, and the number of parts that the
+ytt subcommand would split it into when cleaning it up with that model. The
+text is read from file, or from stdin if no file (or -) is given.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		m, _ := cmd.Flags().GetString("model")
+		model := llm.Model(m)
+		if !llm.Supported(model) {
+			return fmt.Errorf("invalid model: %s", model)
+		}
+
+		var (
+			data []byte
+			err  error
+		)
+		if len(args) == 0 || args[0] == "-" {
+			data, err = io.ReadAll(os.Stdin)
+		} else {
+			data, err = os.ReadFile(args[0])
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read text: %w", err)
+		}
+		text := string(data)
+		if strings.TrimSpace(text) == "" {
+			return errors.New("text is empty")
+		}
+
+		words := len(strings.Fields(text))
+		tokens, encoding, exact, err := llm.CountTokens(model, text)
+		if err != nil {
+			// Fall back to the estimate used for costs
+			fmt.Printf("%v, estimating tokens from the number of words\n", err)
+			tokens, encoding, exact = llm.EstimateUsage(words).InputTokens, "word count", false
+		}
+		chunks, err := llm.SplitText(text, model)
+		if err != nil {
+			return fmt.Errorf("error splitting text: %w", err)
+		}
+
+		fmt.Printf("model: %s\n", model)
+		fmt.Printf("words: %d\n", words)
+		if exact {
+			fmt.Printf("tokens: %d (%s)\n", tokens, encoding)
+		} else {
+			fmt.Printf("tokens: ~%d (approximated with %s)\n", tokens, encoding)
+		}
+		fmt.Printf("output limit: %d tokens\n", llm.MaxTokens(model))
+		fmt.Printf("parts: %d of up to %d words\n", len(chunks), llm.ChunkSize(model))
+		return nil
+	},
+}
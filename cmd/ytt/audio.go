@@ -0,0 +1,269 @@
+package ytt
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	aai "github.com/AssemblyAI/assemblyai-go-sdk"
+	"github.com/deepakjois/podscript/internal/config"
+	"github.com/deepakjois/podscript/internal/download"
+	"github.com/deepakjois/podscript/internal/stt"
+	"github.com/deepakjois/podscript/internal/whisper"
+	"github.com/deepakjois/ytt"
+	prerecorded "github.com/deepgram/deepgram-go-sdk/pkg/api/prerecorded/v1"
+	interfaces "github.com/deepgram/deepgram-go-sdk/pkg/client/interfaces"
+	client "github.com/deepgram/deepgram-go-sdk/pkg/client/prerecorded"
+	"github.com/kkdai/youtube/v2"
+)
+
+// STT backends that can transcribe the audio of videos without captions.
+var sttBackends = []string{config.Groq, config.Deepgram, config.AssemblyAI}
+
+const (
+	// groqTranscriptionsURL transcribes audio in the language it is spoken in,
+	// unlike the translations endpoint used by the groq subcommand.
+	groqTranscriptionsURL = "https://api.groq.com/openai/v1/audio/transcriptions"
+	groqMaxFileSize       = 25 * 1024 * 1024 // 25MB in bytes
+)
+
+// sttMaxFileSize is the size of the largest audio file accepted by each STT
+// backend, beyond which the audio is not downloaded.
+var sttMaxFileSize = map[string]int64{
+	config.Groq:       groqMaxFileSize,
+	config.Deepgram:   2 * 1024 * 1024 * 1024, // 2GB
+	config.AssemblyAI: 2200 * 1024 * 1024,     // about 2.2GB
+}
+
+// validSTTBackend reports whether backend can be used with --stt-fallback. An
+// empty backend means there is no fallback.
+func validSTTBackend(backend string) bool {
+	if backend == "" {
+		return true
+	}
+	for _, b := range sttBackends {
+		if b == backend {
+			return true
+		}
+	}
+	return false
+}
+
+// noCaptions reports whether err means that the video has no captions at all,
+// as opposed to, for e.g., being private or having no captions in the
+// requested language.
+func noCaptions(err error) bool {
+	return errors.Is(err, errCaptionsDisabled) || errors.Is(err, errNoCaptionTracks)
+}
+
+// transcribeAudio downloads the audio of the video and transcribes it with
+// backend, returning the transcript as caption entries. The language of the
+// transcript is language if given, and detected otherwise.
+func transcribeAudio(videoID, language, backend string) (*ytt.Transcript, []ytt.TranscriptEntry, error) {
+	apiKey := config.APIKey(backend)
+	if apiKey == "" {
+		return nil, nil, fmt.Errorf("%s API key not found. Please run 'podscript configure' or set the %s_API_KEY environment variable", backend, strings.ToUpper(backend))
+	}
+
+	audioFile, err := downloadAudio(videoID, download.Limits{MaxSize: sttMaxFileSize[backend]})
+	if err != nil {
+		return nil, nil, err
+	}
+	defer os.Remove(audioFile)
+
+	var (
+		utterances []stt.Utterance
+		detected   string
+	)
+	switch backend {
+	case config.Groq:
+		utterances, err = transcribeGroq(audioFile, language, apiKey)
+	case config.Deepgram:
+		utterances, detected, err = transcribeDeepgram(audioFile, language, apiKey)
+	case config.AssemblyAI:
+		utterances, detected, err = transcribeAssemblyAI(audioFile, language, apiKey)
+	default:
+		err = fmt.Errorf("unsupported STT backend: %s", backend)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to transcribe audio with %s: %w", backend, err)
+	}
+
+	code := language
+	if code == "" {
+		code = detected
+	}
+	if code == "" {
+		code = "en"
+	}
+	transcript := &ytt.Transcript{
+		VideoID:      videoID,
+		Language:     code,
+		LanguageCode: code,
+		IsGenerated:  true,
+	}
+	entries := make([]ytt.TranscriptEntry, 0, len(utterances))
+	for _, u := range utterances {
+		entries = append(entries, ytt.TranscriptEntry{Text: u.Text, Start: u.Start, Duration: u.End - u.Start})
+	}
+	return transcript, entries, nil
+}
+
+// downloadAudio downloads the audio-only stream of the video with the lowest
+// bitrate, which is good enough for speech, to a temporary file and returns
+// its path. The download is stopped as soon as the stream is known to exceed
+// limits.MaxSize.
+func downloadAudio(videoID string, limits download.Limits) (string, error) {
+	yt := youtube.Client{HTTPClient: &http.Client{Transport: http.DefaultTransport}}
+	video, err := yt.GetVideo(videoID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get video: %w", err)
+	}
+
+	var format *youtube.Format
+	formats := video.Formats.WithAudioChannels()
+	for i, f := range formats {
+		if !strings.HasPrefix(f.MimeType, "audio/") {
+			continue
+		}
+		if format == nil || f.Bitrate < format.Bitrate {
+			format = &formats[i]
+		}
+	}
+	if format == nil {
+		return "", errors.New("no audio stream found for video")
+	}
+
+	ext := ".m4a"
+	if strings.HasPrefix(format.MimeType, "audio/webm") {
+		ext = ".webm"
+	}
+	f, err := os.CreateTemp("", "podscript-*"+ext)
+	if err != nil {
+		return "", fmt.Errorf("failed to create audio file: %w", err)
+	}
+	defer f.Close()
+
+	stream, size, err := yt.GetStream(video, format)
+	if err != nil {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("failed to download audio: %w", err)
+	}
+	defer stream.Close()
+	if limits.MaxSize > 0 && size > limits.MaxSize {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("%w: audio is %.1fMB, over the limit of %.1fMB", download.ErrFileTooLarge, float64(size)/(1024*1024), float64(limits.MaxSize)/(1024*1024))
+	}
+
+	fmt.Printf("downloading %.1fMB of audio (%s)…\n", float64(size)/(1024*1024), video.Duration)
+	body := io.Reader(stream)
+	if limits.MaxSize > 0 {
+		// Read one byte more than the limit, to tell if it is exceeded
+		body = io.LimitReader(body, limits.MaxSize+1)
+	}
+	n, err := io.Copy(f, body)
+	if err != nil {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("failed to download audio: %w", err)
+	}
+	if limits.MaxSize > 0 && n > limits.MaxSize {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("%w: audio is over the limit of %.1fMB", download.ErrFileTooLarge, float64(limits.MaxSize)/(1024*1024))
+	}
+	return f.Name(), nil
+}
+
+func transcribeGroq(audioFile, language, apiKey string) ([]stt.Utterance, error) {
+	fi, err := os.Stat(audioFile)
+	if err != nil {
+		return nil, fmt.Errorf("invalid audio file: %s", audioFile)
+	}
+	if fi.Size() > groqMaxFileSize {
+		return nil, fmt.Errorf("audio exceeds the 25MB limit of Groq, use --stt-fallback %s or %s instead", config.Deepgram, config.AssemblyAI)
+	}
+
+	data, err := whisper.Transcribe(whisper.Request{
+		URL:            groqTranscriptionsURL,
+		FilePath:       audioFile,
+		Model:          "whisper-large-v3",
+		Language:       language,
+		ResponseFormat: "verbose_json", // for segment timestamps
+		APIKey:         apiKey,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var resp whisper.Response
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, fmt.Errorf("json parsing failed: %w", err)
+	}
+	utterances := make([]stt.Utterance, len(resp.Segments))
+	for i, s := range resp.Segments {
+		utterances[i] = stt.Utterance{Text: strings.TrimSpace(s.Text), Start: s.Start, End: s.End}
+	}
+	return utterances, nil
+}
+
+func transcribeDeepgram(audioFile, language, apiKey string) ([]stt.Utterance, string, error) {
+	client.InitWithDefault()
+	options := &interfaces.PreRecordedTranscriptionOptions{
+		Model:       "nova-2",
+		SmartFormat: true,
+		Punctuate:   true,
+		Utterances:  true,
+	}
+	if language != "" {
+		options.Language = language
+	} else {
+		options.DetectLanguage = true
+	}
+
+	c := client.New(apiKey, &interfaces.ClientOptions{})
+	// Use the shared transport so that TLS settings from the root command apply
+	c.Transport = http.DefaultTransport
+	res, err := prerecorded.New(c).FromFile(context.Background(), audioFile, options)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var detected string
+	if len(res.Results.Channels) > 0 {
+		detected = res.Results.Channels[0].DetectedLanguage
+	}
+	return stt.DeepgramUtterances(res), detected, nil
+}
+
+func transcribeAssemblyAI(audioFile, language, apiKey string) ([]stt.Utterance, string, error) {
+	f, err := os.Open(audioFile)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to open audio file: %w", err)
+	}
+	defer f.Close()
+
+	// Utterances are only returned with speaker labels
+	params := &aai.TranscriptOptionalParams{
+		SpeakerLabels: aai.Bool(true),
+		Punctuate:     aai.Bool(true),
+		FormatText:    aai.Bool(true),
+	}
+	if language != "" {
+		params.LanguageCode = aai.TranscriptLanguageCode(language)
+	} else {
+		params.LanguageDetection = aai.Bool(true)
+	}
+
+	transcript, err := aai.NewClient(apiKey).Transcripts.TranscribeFromReader(context.Background(), f, params)
+	if err != nil {
+		return nil, "", err
+	}
+	if transcript.Status == aai.TranscriptStatusError {
+		return nil, "", errors.New(aai.ToString(transcript.Error))
+	}
+	return stt.AssemblyAIUtterances(&transcript), string(transcript.LanguageCode), nil
+}
@@ -0,0 +1,150 @@
+package ytt
+
+import (
+	"html"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/deepakjois/ytt"
+)
+
+const (
+	// maxCaptionOverlap is the maximum number of words that are compared when
+	// looking for repetition between consecutive caption entries.
+	maxCaptionOverlap = 30
+	// minCaptionOverlap is the minimum number of repeated words that are
+	// treated as a rolling window repetition, so that legitimate repetitions of
+	// a single word are preserved.
+	minCaptionOverlap = 2
+)
+
+// Kinds of captions that can be preferred with --prefer.
+const (
+	preferManual = "manual"
+	preferAuto   = "auto"
+)
+
+// findTranscript finds the captions for language. If no language is
+// specified, English captions are preferred, falling back to captions in any
+// other language. Captions of the kind given by prefer are chosen over those
+// of the other kind in the same language.
+func findTranscript(list *ytt.TranscriptList, language, prefer string) (*ytt.Transcript, error) {
+	kinds := []map[string]*ytt.Transcript{list.ManuallyCreatedTranscripts, list.GeneratedTranscripts}
+	if prefer == preferAuto {
+		kinds[0], kinds[1] = kinds[1], kinds[0]
+	}
+
+	code := language
+	if code == "" {
+		code = "en"
+	}
+	for _, transcripts := range kinds {
+		if t, ok := transcripts[code]; ok {
+			return t, nil
+		}
+	}
+	if language != "" {
+		return nil, ytt.ErrNoTranscriptFound
+	}
+
+	for _, transcripts := range kinds {
+		codes := make([]string, 0, len(transcripts))
+		for code := range transcripts {
+			codes = append(codes, code)
+		}
+		sort.Strings(codes)
+		if len(codes) > 0 {
+			return transcripts[codes[0]], nil
+		}
+	}
+	return nil, ytt.ErrNoTranscriptFound
+}
+
+// transcriptLanguages returns the sorted language codes of the captions in
+// list.
+func transcriptLanguages(list *ytt.TranscriptList) []string {
+	seen := make(map[string]bool)
+	var codes []string
+	for _, transcripts := range []map[string]*ytt.Transcript{list.ManuallyCreatedTranscripts, list.GeneratedTranscripts} {
+		for code := range transcripts {
+			if !seen[code] {
+				seen[code] = true
+				codes = append(codes, code)
+			}
+		}
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// captionTagRegex matches formatting tags, for e.g. <font color="#fff"> or
+// </i>, but not a literal < in the text, as in "x < y".
+var captionTagRegex = regexp.MustCompile(`</?[a-zA-Z][^<>]*>`)
+
+// sanitizeCaption unescapes HTML entities and removes formatting tags from
+// the text of a caption entry. Captions are sometimes escaped twice, so
+// entities are unescaped until the text no longer changes.
+func sanitizeCaption(text string) string {
+	for {
+		unescaped := html.UnescapeString(text)
+		if unescaped == text {
+			break
+		}
+		text = unescaped
+	}
+	text = captionTagRegex.ReplaceAllString(text, "")
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// dedupeCaptions removes words at the start of each entry that repeat the end
+// of the previous entries. Auto-generated captions are displayed as a rolling
+// window, so consecutive entries often overlap.
+func dedupeCaptions(entries []ytt.TranscriptEntry) []ytt.TranscriptEntry {
+	var (
+		deduped []ytt.TranscriptEntry
+		prev    []string // words emitted so far, up to maxCaptionOverlap
+	)
+	for _, entry := range entries {
+		words := strings.Fields(entry.Text)
+		if n := captionOverlap(prev, words); n > 0 {
+			words = words[n:]
+		}
+		if len(words) == 0 {
+			continue
+		}
+
+		entry.Text = strings.Join(words, " ")
+		deduped = append(deduped, entry)
+
+		prev = append(prev, words...)
+		if len(prev) > maxCaptionOverlap {
+			prev = prev[len(prev)-maxCaptionOverlap:]
+		}
+	}
+	return deduped
+}
+
+// captionOverlap returns the length of the longest suffix of prev that is also
+// a prefix of next.
+func captionOverlap(prev, next []string) int {
+	for n := min(len(prev), len(next)); n >= minCaptionOverlap; n-- {
+		if wordsEqual(prev[len(prev)-n:], next[:n]) {
+			return n
+		}
+	}
+	return 0
+}
+
+func wordsEqual(a, b []string) bool {
+	for i := range a {
+		if !strings.EqualFold(normalizeCaptionWord(a[i]), normalizeCaptionWord(b[i])) {
+			return false
+		}
+	}
+	return true
+}
+
+func normalizeCaptionWord(w string) string {
+	return strings.Trim(w, ".,!?;:\"'")
+}
@@ -0,0 +1,30 @@
+package ytt
+
+import "testing"
+
+func TestSanitizeCaption(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{name: "apostrophe", in: "it&#39;s fine", want: "it's fine"},
+		{name: "quotes", in: "&quot;hello&quot; she said", want: `"hello" she said`},
+		{name: "double escaped", in: "salt &amp;amp; pepper", want: "salt & pepper"},
+		{name: "double escaped apostrophe", in: "don&amp;#39;t", want: "don't"},
+		{name: "font tag", in: `<font color="#E5E5E5">so we</font> started`, want: "so we started"},
+		{name: "italics", in: "<i>[Music]</i>", want: "[Music]"},
+		{name: "escaped tag", in: "&lt;i&gt;quietly&lt;/i&gt;", want: "quietly"},
+		{name: "literal less than", in: "if x < y and y > z", want: "if x < y and y > z"},
+		{name: "escaped less than", in: "I &lt;3 Go", want: "I <3 Go"},
+		{name: "whitespace", in: "  line one\n line  two ", want: "line one line two"},
+		{name: "plain", in: "nothing to do", want: "nothing to do"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sanitizeCaption(tt.in); got != tt.want {
+				t.Errorf("sanitizeCaption(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
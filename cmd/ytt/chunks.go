@@ -0,0 +1,118 @@
+package ytt
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/deepakjois/podscript/internal/output"
+	"github.com/deepakjois/podscript/internal/stt"
+)
+
+// chunkResult is a part of the transcript, along with the cleaned up text or
+// the error returned while cleaning it up.
+type chunkResult struct {
+	Part   int    `json:"part"`
+	Input  string `json:"input"`
+	Output string `json:"output,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+func (c chunkResult) done() bool {
+	return c.Error == "" && c.Output != ""
+}
+
+// chunksFailedError is returned when some parts of the transcript could not be
+// cleaned up. The other parts are cleaned up regardless.
+type chunksFailedError struct {
+	chunks []chunkResult
+}
+
+func (e *chunksFailedError) Error() string {
+	return fmt.Sprintf("%d of %d parts failed", countFailed(e.chunks), len(e.chunks))
+}
+
+func countFailed(chunks []chunkResult) int {
+	failed := 0
+	for _, c := range chunks {
+		if !c.done() {
+			failed++
+		}
+	}
+	return failed
+}
+
+// joinChunks returns the cleaned up transcript, leaving out failed parts.
+func joinChunks(chunks []chunkResult) string {
+	var sb strings.Builder
+	for _, c := range chunks {
+		sb.WriteString(c.Output)
+	}
+	return sb.String()
+}
+
+// failedRun records a run in which some parts failed, so that only those can
+// be cleaned up again with --retry-failed-chunks.
+type failedRun struct {
+	Source       string        `json:"source"`             // URL of the video
+	Language     string        `json:"language,omitempty"` // language of the captions, if not English
+	LanguageCode string        `json:"language_code,omitempty"`
+	Format       string        `json:"format"`
+	Output       string        `json:"output"` // path of the cleaned up transcript
+	Chunks       []chunkResult `json:"chunks"`
+}
+
+func readFailedRun(filename string) (*failedRun, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read failed chunks: %w", err)
+	}
+	var run failedRun
+	if err = json.Unmarshal(data, &run); err != nil {
+		return nil, fmt.Errorf("json parsing failed: %w", err)
+	}
+	return &run, nil
+}
+
+func (r *failedRun) write(filename string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("json.Marshal failed: %w", err)
+	}
+	if err = os.WriteFile(filename, data, 0644); err != nil {
+		return fmt.Errorf("failed to write failed chunks: %w", err)
+	}
+	return nil
+}
+
+// writeCleaned writes the cleaned up parts of the transcript to filename in
+// format. title is the title of HTML pages.
+func writeCleaned(filename, format, title string, chunks []chunkResult) error {
+	switch format {
+	case stt.FormatJSONLines:
+		f, err := output.Create(filename)
+		if err != nil {
+			return fmt.Errorf("failed to create cleaned transcript: %w", err)
+		}
+		enc := json.NewEncoder(f)
+		for _, c := range chunks {
+			if !c.done() {
+				continue
+			}
+			if err = enc.Encode(jsonChunk{Part: c.Part, Text: c.Output}); err != nil {
+				f.Close()
+				return fmt.Errorf("failed to write chunk: %w", err)
+			}
+		}
+		return f.Close()
+	case stt.FormatHTML:
+		var page strings.Builder
+		if err := stt.WriteHTMLText(&page, title, joinChunks(chunks)); err != nil {
+			return err
+		}
+		return output.WriteFile(filename, []byte(page.String()))
+	default:
+		return output.WriteFile(filename, []byte(joinChunks(chunks)))
+	}
+}
@@ -0,0 +1,93 @@
+package ytt
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"text/tabwriter"
+	"time"
+
+	"github.com/deepakjois/podscript/internal/llm"
+	"github.com/deepakjois/podscript/internal/output"
+)
+
+type comparison struct {
+	model    llm.Model
+	filename string
+	elapsed  time.Duration
+	usage    llm.Usage
+	words    int
+	failed   int // parts that could not be cleaned up
+}
+
+// compareModels cleans up the transcript with each of the models, and writes
+// the output of each model along with a table of statistics.
+func compareModels(models []llm.Model, transcript string, opts cleanupOptions, folder, filenameSuffix string) error {
+	var results []comparison
+	for _, model := range models {
+		fmt.Printf("cleaning up transcript using %s…\n", model)
+		tc, err := newTranscriptCleaner(model, opts)
+		if err != nil {
+			return fmt.Errorf("failed to initialize model %s: %v", model, err)
+		}
+
+		start := time.Now()
+		cleaned, usage, err := tc.cleanupTranscript(transcript)
+		// Failed parts are left out of the output of the model, rather than
+		// failing the whole comparison
+		var failed *chunksFailedError
+		if err != nil && !errors.As(err, &failed) {
+			return fmt.Errorf("failed to transcribe using %s: %w", model, err)
+		}
+		elapsed := time.Since(start)
+
+		filename := path.Join(folder, fmt.Sprintf("cleaned_transcript_%s_%s.txt", filenameSuffix, output.SafeName(string(model))))
+		if err = output.WriteFile(filename, []byte(cleaned)); err != nil {
+			return fmt.Errorf("failed to write cleaned transcript: %w", err)
+		}
+		fmt.Printf("wrote cleaned up transcripts to %s\n", filename)
+
+		r := comparison{
+			model:    model,
+			filename: filename,
+			elapsed:  elapsed,
+			usage:    usage.Total(),
+			words:    llm.CountWords(cleaned),
+		}
+		if failed != nil {
+			r.failed = countFailed(failed.chunks)
+			fmt.Printf("%s: %v, left out of %s\n", model, failed, filename)
+		}
+		results = append(results, r)
+	}
+
+	statsFilename := path.Join(folder, fmt.Sprintf("compare_stats_%s.txt", filenameSuffix))
+	f, err := os.Create(statsFilename)
+	if err != nil {
+		return fmt.Errorf("failed to create stats file: %w", err)
+	}
+	defer f.Close()
+
+	fmt.Println()
+	w := tabwriter.NewWriter(io.MultiWriter(os.Stdout, f), 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "MODEL\tINPUT TOKENS\tOUTPUT TOKENS\tCOST (USD)\tTIME\tWORDS\tFAILED PARTS\tFILE")
+	for _, r := range results {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%.4f\t%s\t%d\t%d\t%s\n",
+			r.model,
+			r.usage.InputTokens,
+			r.usage.OutputTokens,
+			llm.Cost(r.model, r.usage),
+			r.elapsed.Round(time.Second),
+			r.words,
+			r.failed,
+			r.filename,
+		)
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("failed to write stats: %w", err)
+	}
+	fmt.Printf("\nwrote comparison stats to %s\n", statsFilename)
+	return nil
+}
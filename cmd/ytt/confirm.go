@@ -0,0 +1,78 @@
+package ytt
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/huh"
+	"github.com/deepakjois/podscript/internal/llm"
+)
+
+// confirmCost prints the estimated cost of cleaning up transcript with each of
+// the models, and asks for confirmation if the total exceeds threshold. When
+// not run interactively, for e.g. from cron or by batch, a warning is printed
+// instead, so that the run doesn't fail or hang.
+func confirmCost(models []llm.Model, transcript string, threshold float64) error {
+	usage := llm.EstimateUsage(llm.CountWords(transcript))
+	var total float64
+	for _, model := range models {
+		total += llm.Cost(model, usage)
+	}
+	fmt.Printf("estimated cost of cleanup: $%.2f\n", total)
+	if total <= threshold {
+		return nil
+	}
+
+	if fi, err := os.Stdin.Stat(); err != nil || fi.Mode()&os.ModeCharDevice == 0 {
+		fmt.Fprintf(os.Stderr, "warning: estimated cost exceeds $%.2f, continuing without confirmation as stdin is not a terminal\n", threshold)
+		return nil
+	}
+
+	confirmed := false
+	err := huh.NewConfirm().
+		Title(fmt.Sprintf("Estimated cost exceeds $%.2f. Continue?", threshold)).
+		Value(&confirmed).
+		Run()
+	if err != nil && err != huh.ErrUserAborted {
+		return err
+	}
+	if !confirmed || err != nil {
+		return fmt.Errorf("cleanup cancelled")
+	}
+	return nil
+}
+
+// confirmPreview prints the cleaned up first part of the transcript, and asks
+// whether to continue with the remaining parts.
+func confirmPreview(preview string, remaining int) error {
+	fmt.Printf("\n%s\n\n", strings.TrimSpace(preview))
+
+	if fi, err := os.Stdin.Stat(); err != nil || fi.Mode()&os.ModeCharDevice == 0 {
+		return fmt.Errorf("--preview requires an interactive terminal")
+	}
+
+	confirmed := false
+	err := huh.NewConfirm().
+		Title(fmt.Sprintf("Continue with the remaining %d part(s)?", remaining)).
+		Value(&confirmed).
+		Run()
+	if err != nil && err != huh.ErrUserAborted {
+		return err
+	}
+	if !confirmed || err != nil {
+		return fmt.Errorf("cleanup cancelled")
+	}
+	return nil
+}
+
+// printUsage prints the tokens used to clean up a transcript, and their cost
+// priced for each model that was used. Nothing is printed if the providers
+// did not report usage.
+func printUsage(usage llm.ModelUsage) {
+	total := usage.Total()
+	if total.InputTokens == 0 && total.OutputTokens == 0 {
+		return
+	}
+	fmt.Printf("used %d input and %d output tokens, costing about $%.4f\n", total.InputTokens, total.OutputTokens, usage.Cost())
+}
@@ -0,0 +1,142 @@
+package ytt
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/deepakjois/ytt"
+)
+
+const watchURL = "https://www.youtube.com/watch?v=%s"
+
+// Errors returned when the captions of a video can't be fetched, with a hint
+// on what to do about them. Apart from errRateLimited and
+// errCaptionsUnavailable, they are permanent, so fetching is not retried.
+var (
+	errInvalidVideoID     = errors.New("invalid video ID, check the URL or ID for typos")
+	errVideoPrivate       = errors.New("video is private, only its owner can view its captions")
+	errVideoRemoved       = errors.New("video is unavailable, it may have been removed or the URL may be mistyped")
+	errVideoRegionBlocked = errors.New("video is not available in your country")
+	errVideoUnplayable    = errors.New("video can't be played")
+	errCaptionsDisabled   = errors.New("captions are disabled for this video, use --stt-fallback or transcribe its audio with the deepgram, groq or assemblyai subcommands instead")
+	errRateLimited        = errors.New("YouTube is limiting requests, wait a while and try again")
+	// errCaptionsUnavailable means no captions were found on the page of a
+	// playable video. Either it has none, or YouTube has changed the page, so
+	// the captions are looked for using the timedtext API too.
+	errCaptionsUnavailable = errors.New("no captions found on the video page")
+	// errNoCaptionTracks means that neither the video page nor the timedtext
+	// API lists any captions for the video.
+	errNoCaptionTracks = errors.New("video has no captions, use --stt-fallback or transcribe its audio with the deepgram, groq or assemblyai subcommands instead")
+)
+
+// maxAttempts is the number of times transcripts are listed before giving up
+// on transient errors.
+const maxAttempts = 3
+
+var playabilityRegex = regexp.MustCompile(`"playabilityStatus":\{"status":"([A-Z_]+)"(?:,"reason":"([^"]*)")?`)
+
+// classifyVideoError replaces errors returned by the ytt library with one of
+// the errors above, when the cause is known. For videos without captions, the
+// video page is fetched again to find out why.
+func classifyVideoError(videoID string, err error) error {
+	switch {
+	case errors.Is(err, ytt.ErrInvalidCharactersInVideoID), errors.Is(err, ytt.ErrVideoIDMinLength):
+		return errInvalidVideoID
+	case errors.Is(err, ytt.ErrTranscriptsDisabled):
+		return errCaptionsDisabled
+	case errors.Is(err, ytt.ErrTranscriptsUnavailable):
+		if statusErr := videoStatus(videoID); statusErr != nil {
+			return statusErr
+		}
+		return errCaptionsUnavailable
+	}
+	return err
+}
+
+// videoStatus fetches the video page and returns an error if the video can't
+// be played, or nil if it can (or its status can't be found).
+func videoStatus(videoID string) error {
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf(watchURL, videoID), nil)
+	if err != nil {
+		return nil
+	}
+	// The reason for unplayable videos is matched in English
+	req.Header.Set("Accept-Language", "en-US,en")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return errRateLimited
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if strings.Contains(string(body), "g-recaptcha") {
+		return errRateLimited
+	}
+
+	m := playabilityRegex.FindStringSubmatch(string(body))
+	if m == nil {
+		return nil
+	}
+	status, reason := m[1], m[2]
+	lower := strings.ToLower(reason)
+	switch {
+	case status == "OK":
+		return nil
+	case strings.Contains(lower, "private"):
+		return errVideoPrivate
+	case strings.Contains(lower, "country"):
+		return errVideoRegionBlocked
+	case status == "ERROR":
+		return errVideoRemoved
+	case reason != "":
+		return fmt.Errorf("%w: %s", errVideoUnplayable, reason)
+	default:
+		return errVideoUnplayable
+	}
+}
+
+// transient reports whether fetching captions failed due to an error that may
+// go away when retried.
+func transient(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, errRateLimited) || errors.As(err, &netErr)
+}
+
+// permanent reports whether err means that the captions of the video can't be
+// fetched at all, so there is no point in falling back to another API.
+func permanent(err error) bool {
+	for _, e := range []error{errInvalidVideoID, errVideoPrivate, errVideoRemoved, errVideoRegionBlocked, errVideoUnplayable, errCaptionsDisabled} {
+		if errors.Is(err, e) {
+			return true
+		}
+	}
+	return false
+}
+
+// listTranscripts lists the transcripts of the video, retrying transient
+// errors.
+func listTranscripts(videoID string) (*ytt.TranscriptList, error) {
+	for attempt := 1; ; attempt++ {
+		transcriptList, err := ytt.ListTranscripts(videoID)
+		if err == nil {
+			return transcriptList, nil
+		}
+		err = classifyVideoError(videoID, err)
+		if !transient(err) || attempt == maxAttempts {
+			return nil, err
+		}
+		fmt.Printf("failed to list transcripts: %v, retrying…\n", err)
+		time.Sleep(time.Duration(attempt) * 2 * time.Second)
+	}
+}
@@ -0,0 +1,55 @@
+package ytt
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/deepakjois/podscript/internal/llm"
+)
+
+// pacer spaces out requests to a model, so that the tokens they use stay
+// under a rate limit instead of the requests failing with 429 errors. A nil
+// pacer does not pace requests.
+type pacer struct {
+	model           llm.Model
+	tokensPerMinute int
+	next            time.Time // earliest time for the next request
+}
+
+func newPacer(model llm.Model, tokensPerMinute int) *pacer {
+	if tokensPerMinute <= 0 {
+		return nil
+	}
+	return &pacer{model: model, tokensPerMinute: tokensPerMinute}
+}
+
+// newPacers returns a pacer for each of the models, as each has its own rate
+// limit. Models that are not paced have a nil pacer.
+func newPacers(models []llm.Model, settings llm.Settings) map[llm.Model]*pacer {
+	pacers := make(map[llm.Model]*pacer)
+	for _, m := range models {
+		pacers[m] = newPacer(m, llm.TokensPerMinute(llm.ModelSettings(m, settings)))
+	}
+	return pacers
+}
+
+// wait waits until the next request can be made.
+func (p *pacer) wait() {
+	if p == nil {
+		return
+	}
+	if d := time.Until(p.next); d > 0 {
+		fmt.Fprintf(os.Stderr, "waiting %s to stay under %d tokens per minute for %s…\n", d.Round(time.Second), p.tokensPerMinute, p.model)
+		time.Sleep(d)
+	}
+}
+
+// record records the tokens used by a request made at start.
+func (p *pacer) record(start time.Time, usage llm.Usage) {
+	if p == nil {
+		return
+	}
+	tokens := usage.InputTokens + usage.OutputTokens
+	p.next = start.Add(time.Duration(float64(tokens) / float64(p.tokensPerMinute) * float64(time.Minute)))
+}
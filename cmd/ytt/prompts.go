@@ -0,0 +1,187 @@
+package ytt
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// promptSet is the cleanup prompt and its instructions in one language.
+type promptSet struct {
+	template      string // takes the captions, the editing and the content instructions
+	removeFillers string
+	keepFillers   string
+	keepContent   string
+	condense      string
+	light         string // appended to keepFillers for the light clean level
+	heavy         string // appended to removeFillers for the heavy clean level
+}
+
+// localizedPrompts are cleanup prompts written in the language of the
+// captions, by language code. Instructing a model in the language of the
+// transcript gives better results than instructing it in English. Captions in
+// other languages use the English prompt.
+var localizedPrompts = map[string]promptSet{
+	"es": {
+		template: `Recibirás subtítulos generados automáticamente de un vídeo de YouTube. Pueden ser los subtítulos completos o un fragmento de la transcripción completa si es demasiado larga. Tu tarea es transformar estos subtítulos en una transcripción limpia y legible. Estos son los subtítulos generados automáticamente:
+
+<captions>
+%s
+</captions>
+
+Sigue estos pasos para crear una transcripción limpia:
+
+1. Corrige los errores ortográficos que encuentres. Usa tu conocimiento de las palabras comunes y el contexto para determinar la ortografía correcta.
+
+2. Añade la puntuación adecuada en todo el texto, incluidas comas, puntos y signos de interrogación y de exclamación donde sea necesario.
+
+3. Escribe con mayúscula la primera letra de cada oración y los nombres propios.
+
+4. Divide el texto en párrafos lógicos. Empieza un nuevo párrafo cuando haya un cambio de tema o de hablante.
+
+5. %s
+
+6. %s
+
+7. Escribe la transcripción en español y no la traduzcas a ningún otro idioma.
+
+
+Cuando hayas completado estos pasos, escribe la transcripción limpia entre las etiquetas <transcript> y </transcript>. Asegúrate de que la transcripción esté bien formateada, sea fácil de leer y represente fielmente el contenido original del vídeo. No incluyas ningún texto adicional en tu respuesta.`,
+		removeFillers: "Elimina las muletillas innecesarias, las repeticiones y los falsos comienzos.",
+		keepFillers:   "Conserva todas las muletillas (como \"eh\", \"este\" y \"o sea\"), las repeticiones y los falsos comienzos exactamente como se dijeron, para que la transcripción siga siendo literal.",
+		keepContent:   "Mantén el significado y la intención originales de la transcripción. No elimines ningún contenido aunque no esté relacionado con el tema principal.",
+		condense:      "Mantén el significado y la intención originales de la transcripción, pero resúmela: acorta los pasajes extensos y elimina las digresiones, la charla trivial y los puntos repetidos, para que la transcripción sea concisa.",
+		light:         " Aparte de corregir la ortografía, no cambies, añadas, elimines ni reordenes ninguna palabra.",
+		heavy:         " Edita también el texto para que sea legible: corrige los errores gramaticales y reformula las oraciones divagantes o fragmentadas para que sean claras y concisas, conservando la voz del hablante.",
+	},
+	"fr": {
+		template: `Tu vas recevoir des sous-titres générés automatiquement à partir d'une vidéo YouTube. Il peut s'agir des sous-titres complets ou d'un extrait de la transcription complète si elle est trop longue. Ta tâche est de transformer ces sous-titres en une transcription propre et lisible. Voici les sous-titres générés automatiquement :
+
+<captions>
+%s
+</captions>
+
+Suis ces étapes pour créer une transcription propre :
+
+1. Corrige les fautes d'orthographe que tu rencontres. Utilise ta connaissance des mots courants et le contexte pour déterminer l'orthographe correcte.
+
+2. Ajoute la ponctuation appropriée dans tout le texte, y compris les virgules, les points, les points d'interrogation et d'exclamation si nécessaire.
+
+3. Mets une majuscule à la première lettre de chaque phrase et aux noms propres.
+
+4. Divise le texte en paragraphes logiques. Commence un nouveau paragraphe lorsqu'il y a un changement de sujet ou d'intervenant.
+
+5. %s
+
+6. %s
+
+7. Écris la transcription en français et ne la traduis dans aucune autre langue.
+
+
+Une fois ces étapes terminées, fournis la transcription propre entre les balises <transcript> et </transcript>. Assure-toi que la transcription est bien mise en forme, facile à lire et qu'elle représente fidèlement le contenu original de la vidéo. N'inclus aucun texte supplémentaire dans ta réponse.`,
+		removeFillers: "Supprime les mots de remplissage inutiles, les répétitions et les faux départs.",
+		keepFillers:   "Conserve tous les mots de remplissage (comme « euh », « ben » et « tu vois »), les répétitions et les faux départs exactement tels qu'ils ont été prononcés, afin que la transcription reste verbatim.",
+		keepContent:   "Conserve le sens et l'intention d'origine de la transcription. Ne supprime aucun contenu, même s'il n'a pas de rapport avec le sujet principal.",
+		condense:      "Conserve le sens et l'intention d'origine de la transcription, mais condense-la : raccourcis les passages trop longs et supprime les digressions, les bavardages et les points répétés, afin que la transcription soit concise.",
+		light:         " À part corriger l'orthographe, ne modifie, n'ajoute, ne supprime ni ne réordonne aucun mot.",
+		heavy:         " Retravaille aussi le texte pour qu'il soit lisible : corrige les fautes de grammaire et reformule les phrases décousues ou fragmentées en phrases claires et concises, tout en gardant la voix de l'intervenant.",
+	},
+	"de": {
+		template: `Du erhältst automatisch generierte Untertitel aus einem YouTube-Video. Dabei kann es sich um die vollständigen Untertitel handeln oder um einen Ausschnitt des vollständigen Transkripts, falls es zu lang ist. Deine Aufgabe ist es, diese Untertitel in ein sauberes, gut lesbares Transkript umzuwandeln. Hier sind die automatisch generierten Untertitel:
+
+<captions>
+%s
+</captions>
+
+Befolge diese Schritte, um ein sauberes Transkript zu erstellen:
+
+1. Korrigiere alle Rechtschreibfehler, auf die du stößt. Nutze dein Wissen über gebräuchliche Wörter und den Kontext, um die richtige Schreibweise zu bestimmen.
+
+2. Füge im gesamten Text die passende Zeichensetzung ein, einschließlich Kommas, Punkten, Frage- und Ausrufezeichen, wo nötig.
+
+3. Achte auf die korrekte Groß- und Kleinschreibung, insbesondere am Satzanfang, bei Substantiven und bei Eigennamen.
+
+4. Gliedere den Text in sinnvolle Absätze. Beginne einen neuen Absatz, wenn das Thema oder der Sprecher wechselt.
+
+5. %s
+
+6. %s
+
+7. Schreibe das Transkript auf Deutsch und übersetze es in keine andere Sprache.
+
+
+Wenn du diese Schritte abgeschlossen hast, gib das saubere Transkript zwischen den Tags <transcript> und </transcript> aus. Achte darauf, dass das Transkript gut formatiert und leicht lesbar ist und den ursprünglichen Inhalt des Videos genau wiedergibt. Füge deiner Antwort keinen weiteren Text hinzu.`,
+		removeFillers: "Entferne unnötige Füllwörter, Wiederholungen und abgebrochene Satzanfänge.",
+		keepFillers:   "Behalte alle Füllwörter (wie „äh“, „ähm“ und „also“), Wiederholungen und abgebrochenen Satzanfänge genau so bei, wie sie gesprochen wurden, damit das Transkript wortgetreu bleibt.",
+		keepContent:   "Bewahre die ursprüngliche Bedeutung und Absicht des Transkripts. Entferne keine Inhalte, auch wenn sie nichts mit dem Hauptthema zu tun haben.",
+		condense:      "Bewahre die ursprüngliche Bedeutung und Absicht des Transkripts, aber fasse es zusammen: Kürze langatmige Passagen und entferne Abschweifungen, Smalltalk und wiederholte Punkte, damit das Transkript prägnant ist.",
+		light:         " Abgesehen von der Korrektur der Rechtschreibung darfst du keine Wörter ändern, hinzufügen, entfernen oder umstellen.",
+		heavy:         " Überarbeite den Text außerdem für bessere Lesbarkeit: Korrigiere Grammatikfehler und formuliere weitschweifige oder bruchstückhafte Sätze klar und prägnant um, wobei die Ausdrucksweise des Sprechers erhalten bleibt.",
+	},
+	"ja": {
+		template: `YouTube動画の自動生成字幕が与えられます。字幕全体の場合もあれば、長すぎる場合は文字起こし全体の一部の場合もあります。あなたの仕事は、この字幕を整った読みやすい文字起こしに変換することです。自動生成字幕は次のとおりです：
+
+<captions>
+%s
+</captions>
+
+次の手順に従って、整った文字起こしを作成してください：
+
+1. 誤字や誤変換を見つけたら修正してください。一般的な語彙と文脈から正しい表記を判断してください。
+
+2. 文章全体に、読点、句点、疑問符、感嘆符などの適切な句読点を必要に応じて追加してください。
+
+3. 固有名詞やアルファベットの表記を正しく統一してください。
+
+4. 文章を論理的な段落に分けてください。話題や話者が変わったら新しい段落を始めてください。
+
+5. %s
+
+6. %s
+
+7. 文字起こしは日本語で書き、他の言語に翻訳しないでください。
+
+
+これらの手順を終えたら、整った文字起こしを <transcript> タグと </transcript> タグの間に出力してください。文字起こしが適切に整形されて読みやすく、動画の元の内容を正確に表していることを確認してください。回答には他のテキストを含めないでください。`,
+		removeFillers: "不要なフィラー（つなぎの言葉）、繰り返し、言い直しを削除してください。",
+		keepFillers:   "すべてのフィラー（「えー」「あの」「まあ」など）、繰り返し、言い直しを話されたとおりに残し、逐語的な文字起こしにしてください。",
+		keepContent:   "文字起こしの元の意味と意図を保ってください。本題と関係のない内容であっても削除しないでください。",
+		condense:      "文字起こしの元の意味と意図を保ちつつ、簡潔にまとめてください。冗長な箇所を短くし、脱線、雑談、繰り返しの内容を削除してください。",
+		light:         "誤字の修正以外では、語句を変更、追加、削除、並べ替えしないでください。",
+		heavy:         "また、読みやすさのために文章を編集してください。文法の誤りを直し、まとまりのない文や断片的な文を、話者の口調を保ちながら明確で簡潔な文に書き直してください。",
+	},
+}
+
+// localizedPrompt returns the prompt set for the language with code, for e.g.
+// "es" or "fr-CA", if there is one.
+func localizedPrompt(code string) (promptSet, bool) {
+	base, _, _ := strings.Cut(strings.ToLower(code), "-")
+	p, ok := localizedPrompts[base]
+	return p, ok
+}
+
+// captionsPlaceholder is replaced with the captions in a custom prompt.
+const captionsPlaceholder = "{{captions}}"
+
+// readCustomPrompt reads a prompt that replaces the built-in cleanup prompt.
+func readCustomPrompt(filename string) (string, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return "", fmt.Errorf("failed to read prompt file: %w", err)
+	}
+	prompt := strings.TrimSpace(string(data))
+	if prompt == "" {
+		return "", fmt.Errorf("prompt file is empty: %s", filename)
+	}
+	return prompt, nil
+}
+
+// customPrompt returns the custom prompt with the captions in chunk. They
+// replace {{captions}} if it occurs in the prompt, and are appended in
+// <captions> tags otherwise.
+func customPrompt(prompt, chunk string) string {
+	if strings.Contains(prompt, captionsPlaceholder) {
+		return strings.ReplaceAll(prompt, captionsPlaceholder, chunk)
+	}
+	return fmt.Sprintf("%s\n\n<captions>\n%s\n</captions>", prompt, chunk)
+}
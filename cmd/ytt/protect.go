@@ -0,0 +1,79 @@
+package ytt
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// protectedTerm matches a term that must be written exactly, in any casing.
+type protectedTerm struct {
+	term  string
+	regex *regexp.Regexp
+}
+
+// compileProtectedTerms returns matchers for terms. Terms are matched as whole
+// words, so that "Go" does not match the start of "good".
+func compileProtectedTerms(terms []string) []protectedTerm {
+	protected := make([]protectedTerm, len(terms))
+	for i, term := range terms {
+		pattern := regexp.QuoteMeta(term)
+		if r, _ := utf8.DecodeRuneInString(term); isWordRune(r) {
+			pattern = `\b` + pattern
+		}
+		if r, _ := utf8.DecodeLastRuneInString(term); isWordRune(r) {
+			pattern += `\b`
+		}
+		protected[i] = protectedTerm{term: term, regex: regexp.MustCompile(`(?i)` + pattern)}
+	}
+	return protected
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// restoreTerms replaces occurrences of protected terms in text, the cleaned
+// up input, with the term as written in the list where cleanup changed their
+// casing. Only terms that occur in input are restored, and a casing that also
+// occurs in input is kept, so that for e.g. with the term "Go" the verb "go"
+// is left as is. It returns the restored text and the number of occurrences
+// restored.
+func restoreTerms(input, text string, protected []protectedTerm) (string, int) {
+	restored := 0
+	for _, p := range protected {
+		inInput := make(map[string]bool)
+		for _, match := range p.regex.FindAllString(input, -1) {
+			inInput[match] = true
+		}
+		if len(inInput) == 0 {
+			continue
+		}
+		text = p.regex.ReplaceAllStringFunc(text, func(match string) string {
+			if match == p.term || inInput[match] {
+				return match
+			}
+			restored++
+			return p.term
+		})
+	}
+	return text, restored
+}
+
+// missingTerms returns the protected terms that occur in the captions but not
+// in the cleaned up text, in any casing.
+func missingTerms(captions, cleaned string, protected []protectedTerm) []string {
+	var missing []string
+	for _, p := range protected {
+		if p.regex.MatchString(captions) && !p.regex.MatchString(cleaned) {
+			missing = append(missing, p.term)
+		}
+	}
+	return missing
+}
+
+// protectedTermsSummary formats terms for a warning.
+func protectedTermsSummary(terms []string) string {
+	return `"` + strings.Join(terms, `", "`) + `"`
+}
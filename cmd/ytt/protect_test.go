@@ -0,0 +1,29 @@
+package ytt
+
+import "testing"
+
+func TestRestoreTerms(t *testing.T) {
+	protected := compileProtectedTerms([]string{"Go", "iPhone", "PostgreSQL"})
+	tests := []struct {
+		name     string
+		input    string
+		text     string
+		want     string
+		restored int
+	}{
+		{name: "changed casing", input: "i bought an iphone", text: "I bought an Iphone.", want: "I bought an iPhone.", restored: 1},
+		{name: "same casing as input", input: "let's go write some code", text: "Let's go write some code.", want: "Let's go write some code.", restored: 0},
+		{name: "mixed", input: "we go and use go", text: "We go and use GO.", want: "We go and use Go.", restored: 1},
+		{name: "not in input", input: "nothing to see here", text: "Nothing to see here, go on.", want: "Nothing to see here, go on.", restored: 0},
+		{name: "whole words", input: "postgresql is good", text: "Postgresql is good.", want: "PostgreSQL is good.", restored: 1},
+		{name: "already correct", input: "iphone", text: "iPhone", want: "iPhone", restored: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, restored := restoreTerms(tt.input, tt.text, protected)
+			if got != tt.want || restored != tt.restored {
+				t.Errorf("restoreTerms(%q, %q) = %q, %d, want %q, %d", tt.input, tt.text, got, restored, tt.want, tt.restored)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package ytt
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/deepakjois/podscript/internal/llm"
+)
+
+// refusalPrefixes are the starts of responses in which a model apologizes or
+// declines instead of cleaning up the captions.
+var refusalPrefixes = []string{"i'm sorry", "i am sorry", "i apologize", "as an ai", "i cannot", "i can't", "sorry,"}
+
+// minQuality is the lowest ratio of output to input words of a chunk that is
+// accepted as a transcript at all. It is well below minFidelity, as output
+// this short is almost certainly truncated rather than edited.
+const minQuality = 0.3
+
+func refusal(s string) bool {
+	s = strings.ToLower(strings.TrimSpace(s))
+	for _, p := range refusalPrefixes {
+		if strings.HasPrefix(s, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// qualityIssue returns a description of the problem if the response of the
+// model for chunk is not a usable transcript, or an empty string otherwise.
+// cleaned is the transcript extracted from the response.
+func (tc transcriptCleaner) qualityIssue(chunk, response, cleaned string) string {
+	switch {
+	case refusal(response) || refusal(cleaned):
+		return "model apologized or declined instead of returning a transcript"
+	case cleaned == "":
+		return "model returned an empty transcript"
+	case strings.Contains(cleaned, "<captions>") || strings.Contains(cleaned, "Follow these steps to create a clean transcript"):
+		return "model echoed the prompt back"
+	}
+	in, out := llm.CountWords(chunk), llm.CountWords(cleaned)
+	if !tc.opts.condense && in > 0 && float64(out)/float64(in) < minQuality {
+		return fmt.Sprintf("model returned a much shorter transcript (%d words in, %d words out)", in, out)
+	}
+	return ""
+}
+
+// unusableResponseError is returned by generateChecked when the response of
+// the model is still not a usable transcript after a retry.
+type unusableResponseError string
+
+func (e unusableResponseError) Error() string {
+	return string(e)
+}
+
+// generateChecked cleans up chunk, and checks that the response is a usable
+// transcript. A chunk with an unusable response is retried once.
+func (tc transcriptCleaner) generateChecked(part int, chunk string) (string, llm.ModelUsage, llm.Model, error) {
+	var totalUsage llm.ModelUsage
+	for attempt := 1; ; attempt++ {
+		response, usage, model, err := tc.generate(chunk)
+		totalUsage = totalUsage.Add(model, usage)
+		if err != nil {
+			return "", totalUsage, model, err
+		}
+		cleaned := extractTranscript(response)
+		issue := tc.qualityIssue(chunk, response, cleaned)
+		if issue == "" {
+			return cleaned, totalUsage, model, nil
+		}
+		if attempt == 2 {
+			return "", totalUsage, model, unusableResponseError(issue)
+		}
+		fmt.Printf("part %d: %s, retrying…\n", part, issue)
+	}
+}
+
+// Thresholds used by alreadyClean. Autogenerated captions have little or no
+// punctuation and are mostly lowercase, while manual captions read like
+// written text.
+const (
+	minCleanWords       = 20  // shorter text is always cleaned up
+	maxWordsPerSentence = 30  // average length of sentences in clean text
+	minCapitalized      = 0.9 // share of sentences that start with a capital letter
+)
+
+// alreadyClean reports whether text looks like it is already punctuated and
+// capitalized, for e.g. manual captions, so that cleaning it up can be
+// skipped.
+func alreadyClean(text string) bool {
+	words := llm.CountWords(text)
+	if words < minCleanWords {
+		return false
+	}
+
+	// A sentence starts at the first letter after a full stop, question or
+	// exclamation mark that is followed by a space, so that for e.g. decimals
+	// are not counted. Full-width marks need no space.
+	var sentences, capitalized int
+	start, stop := true, false
+	for _, r := range text {
+		switch {
+		case strings.ContainsRune("。？！", r):
+			start, stop = true, false
+		case strings.ContainsRune(".?!", r):
+			stop = true
+		case unicode.IsSpace(r):
+			if stop {
+				start, stop = true, false
+			}
+		case unicode.IsLetter(r):
+			if start {
+				sentences++
+				if !unicode.IsLower(r) {
+					capitalized++
+				}
+			}
+			start, stop = false, false
+		default:
+			stop = false
+		}
+	}
+	if sentences == 0 {
+		return false
+	}
+	return words/sentences <= maxWordsPerSentence && float64(capitalized)/float64(sentences) >= minCapitalized
+}
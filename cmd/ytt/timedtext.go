@@ -0,0 +1,209 @@
+package ytt
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/deepakjois/ytt"
+)
+
+const (
+	timedTextURL = "https://www.youtube.com/api/timedtext"
+	oEmbedURL    = "https://www.youtube.com/oembed"
+)
+
+// timedText is the JSON3 format of YouTube's timedtext API.
+type timedText struct {
+	Events []struct {
+		TStartMs    float64 `json:"tStartMs"`
+		DDurationMs float64 `json:"dDurationMs"`
+		Segs        []struct {
+			UTF8 string `json:"utf8"`
+		} `json:"segs"`
+	} `json:"events"`
+}
+
+// fetchCaptions fetches the captions for language (see findTranscript) using
+// the ytt library. If that fails, for e.g. because YouTube has changed the
+// video page, the captions are fetched from the timedtext API directly,
+// unless the video itself is unavailable. Captions of the kind given by prefer
+// are chosen over those of the other kind.
+func fetchCaptions(videoID, language, prefer string) (*ytt.Transcript, []ytt.TranscriptEntry, error) {
+	transcript, entries, err := fetchTranscript(videoID, language, prefer)
+	if err == nil {
+		return transcript, entries, nil
+	}
+	if permanent(err) {
+		return nil, nil, err
+	}
+
+	transcript, entries, ttErr := fetchTimedText(videoID, language, prefer)
+	if ttErr != nil {
+		if errors.Is(err, errCaptionsUnavailable) && errors.Is(ttErr, ytt.ErrNoTranscriptFound) {
+			// Find out whether the video has captions in other languages
+			if codes, listErr := timedTextLanguages(videoID); listErr == nil {
+				if len(codes) == 0 {
+					return nil, nil, errNoCaptionTracks
+				}
+				return nil, nil, fmt.Errorf("failed to find transcript: %w (available languages: %s)", ytt.ErrNoTranscriptFound, strings.Join(codes, ", "))
+			}
+		}
+		return nil, nil, fmt.Errorf("%w (timedtext fallback: %v)", err, ttErr)
+	}
+	fmt.Printf("fetched captions from the timedtext API, as the transcript could not be fetched: %v\n", err)
+	return transcript, entries, nil
+}
+
+func fetchTranscript(videoID, language, prefer string) (*ytt.Transcript, []ytt.TranscriptEntry, error) {
+	transcriptList, err := listTranscripts(videoID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list transcripts: %w", err)
+	}
+
+	transcript, err := findTranscript(transcriptList, language, prefer)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to find transcript: %w (available languages: %s)", err, strings.Join(transcriptLanguages(transcriptList), ", "))
+	}
+
+	entries, err := transcript.Fetch()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch transcript: %w", err)
+	}
+	return transcript, entries, nil
+}
+
+// fetchTimedText fetches the captions for language (English, if it is empty)
+// in JSON3 format from the timedtext API. Manually created captions are
+// preferred over auto-generated ones, unless prefer is auto.
+func fetchTimedText(videoID, language, prefer string) (*ytt.Transcript, []ytt.TranscriptEntry, error) {
+	if language == "" {
+		language = "en"
+	}
+
+	kinds := []string{"", "asr"}
+	if prefer == preferAuto {
+		kinds = []string{"asr", ""}
+	}
+	for _, kind := range kinds {
+		entries, err := fetchJSON3(videoID, language, kind)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(entries) > 0 {
+			transcript := &ytt.Transcript{
+				VideoID:      videoID,
+				Language:     language,
+				LanguageCode: language,
+				IsGenerated:  kind == "asr",
+			}
+			return transcript, entries, nil
+		}
+	}
+	return nil, nil, ytt.ErrNoTranscriptFound
+}
+
+func fetchJSON3(videoID, language, kind string) ([]ytt.TranscriptEntry, error) {
+	params := url.Values{"v": {videoID}, "lang": {language}, "fmt": {"json3"}}
+	if kind != "" {
+		params.Set("kind", kind)
+	}
+
+	resp, err := http.Get(timedTextURL + "?" + params.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("error making request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("timedtext request failed with status code %d", resp.StatusCode)
+	}
+	// An empty response means there are no captions of this kind
+	if len(strings.TrimSpace(string(body))) == 0 {
+		return nil, nil
+	}
+
+	var tt timedText
+	if err = json.Unmarshal(body, &tt); err != nil {
+		return nil, fmt.Errorf("json parsing failed: %w", err)
+	}
+
+	var entries []ytt.TranscriptEntry
+	for _, event := range tt.Events {
+		var text strings.Builder
+		for _, seg := range event.Segs {
+			text.WriteString(seg.UTF8)
+		}
+		t := strings.TrimSpace(strings.ReplaceAll(text.String(), "\n", " "))
+		if t == "" {
+			continue
+		}
+		entries = append(entries, ytt.TranscriptEntry{
+			Text:     t,
+			Start:    event.TStartMs / 1000,
+			Duration: event.DDurationMs / 1000,
+		})
+	}
+	return entries, nil
+}
+
+// timedTextLanguages returns the language codes of the captions of the video
+// listed by the timedtext API.
+func timedTextLanguages(videoID string) ([]string, error) {
+	resp, err := http.Get(timedTextURL + "?" + url.Values{"v": {videoID}, "type": {"list"}}.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("error making request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("timedtext request failed with status code %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading response: %w", err)
+	}
+	var list struct {
+		Tracks []struct {
+			LangCode string `xml:"lang_code,attr"`
+		} `xml:"track"`
+	}
+	if len(strings.TrimSpace(string(body))) > 0 {
+		if err = xml.Unmarshal(body, &list); err != nil {
+			return nil, fmt.Errorf("xml parsing failed: %w", err)
+		}
+	}
+
+	codes := make([]string, 0, len(list.Tracks))
+	for _, t := range list.Tracks {
+		codes = append(codes, t.LangCode)
+	}
+	return codes, nil
+}
+
+// videoTitle returns the title of the video, or its ID if the title can't be
+// fetched.
+func videoTitle(videoID string) string {
+	videoURL := fmt.Sprintf(watchURL, videoID)
+	resp, err := http.Get(oEmbedURL + "?" + url.Values{"url": {videoURL}, "format": {"json"}}.Encode())
+	if err != nil {
+		return videoID
+	}
+	defer resp.Body.Close()
+	var oembed struct {
+		Title string `json:"title"`
+	}
+	if resp.StatusCode != http.StatusOK || json.NewDecoder(resp.Body).Decode(&oembed) != nil || oembed.Title == "" {
+		return videoID
+	}
+	return oembed.Title
+}
@@ -0,0 +1,156 @@
+package ytt
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/deepakjois/podscript/internal/llm"
+	"github.com/deepakjois/ytt"
+)
+
+const (
+	// alignPrefix is the number of words at the start of a paragraph that
+	// are looked for in the captions.
+	alignPrefix = 8
+	// alignLookahead is the number of caption words searched for each word of
+	// the paragraph, so that removed fillers and repetitions are skipped.
+	alignLookahead = 4
+	// alignWindow is the number of caption words around the expected start of
+	// a paragraph in which it is looked for.
+	alignWindow = 300
+)
+
+// timedWord is a word of the captions with the time at which it is spoken.
+type timedWord struct {
+	text  string // normalized with alignToken
+	start float64
+}
+
+// timedWords returns the words of the captions in entries. Times within an
+// entry are interpolated, assuming words are spoken at an even pace.
+func timedWords(entries []ytt.TranscriptEntry) []timedWord {
+	var words []timedWord
+	for _, entry := range entries {
+		fields := strings.Fields(entry.Text)
+		for i, f := range fields {
+			words = append(words, timedWord{
+				text:  alignToken(f),
+				start: entry.Start + entry.Duration*float64(i)/float64(len(fields)),
+			})
+		}
+	}
+	return words
+}
+
+// alignToken normalizes a word for alignment, ignoring case and punctuation.
+func alignToken(word string) string {
+	return strings.ToLower(strings.TrimFunc(word, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	}))
+}
+
+// markTimestamps prefixes each paragraph of cleaned, the cleaned up text of
+// the caption words in words[from:to], with the time at which it starts. Each
+// paragraph is aligned with the captions by looking for its first words near
+// where it is expected to start. If they can't be found, for e.g. because the
+// model rephrased them, the start is estimated from the length of the
+// preceding paragraphs.
+func markTimestamps(cleaned string, words []timedWord, from, to int) string {
+	if from >= to || to > len(words) {
+		return cleaned
+	}
+	ratio := float64(to-from) / float64(max(llm.CountWords(cleaned), 1))
+
+	cursor := from
+	lines := strings.Split(cleaned, "\n")
+	for i, line := range lines {
+		if strings.TrimSpace(line) == "" || (i > 0 && strings.TrimSpace(lines[i-1]) != "") {
+			continue
+		}
+		var tokens []string
+		for _, f := range strings.Fields(line) {
+			if t := alignToken(f); t != "" {
+				tokens = append(tokens, t)
+			}
+		}
+		if pos, ok := alignParagraph(tokens, words, cursor, from, to); ok {
+			cursor = pos
+		}
+		n := paragraphWords(lines[i:])
+		lines[i] = fmt.Sprintf("[%s] %s", paragraphTime(words[cursor].start), line)
+
+		// The next paragraph is expected after this one
+		cursor = min(cursor+int(float64(n)*ratio), to-1)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// alignParagraph returns the position in words[from:to] near cursor at which
+// the start of the paragraph with tokens matches the captions best. It
+// reports false if no position matches well enough.
+func alignParagraph(tokens []string, words []timedWord, cursor, from, to int) (int, bool) {
+	if len(tokens) == 0 {
+		return 0, false
+	}
+	prefix := tokens[:min(len(tokens), alignPrefix)]
+	best, bestScore := 0, 0
+	for pos := max(from, cursor-alignWindow); pos < min(to, cursor+alignWindow); pos++ {
+		score, first := matchScore(prefix, words[pos:to])
+		if score == 0 {
+			continue
+		}
+		if start := pos + first; score > bestScore || (score == bestScore && abs(start-cursor) < abs(best-cursor)) {
+			best, bestScore = start, score
+		}
+	}
+	return best, bestScore >= min(3, len(prefix))
+}
+
+// matchScore returns the number of tokens found in order at the start of
+// words, allowing a few caption words to be skipped before each, along with
+// the index of the first word that matched.
+func matchScore(tokens []string, words []timedWord) (int, int) {
+	score, next, first := 0, 0, 0
+	for _, t := range tokens {
+		for k := next; k < min(next+alignLookahead, len(words)); k++ {
+			if words[k].text == t {
+				if score == 0 {
+					first = k
+				}
+				score, next = score+1, k+1
+				break
+			}
+		}
+	}
+	return score, first
+}
+
+// paragraphWords returns the number of words in the paragraph that starts at
+// the first of lines.
+func paragraphWords(lines []string) int {
+	n := 0
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			break
+		}
+		n += llm.CountWords(line)
+	}
+	return n
+}
+
+// paragraphTime formats seconds as mm:ss, or h:mm:ss from an hour.
+func paragraphTime(seconds float64) string {
+	s := int(seconds)
+	if s >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", s/3600, s/60%60, s%60)
+	}
+	return fmt.Sprintf("%02d:%02d", s/60, s%60)
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -1,16 +1,26 @@
 package ytt
 
 import (
+	"bufio"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path"
 	"regexp"
 	"strings"
+	"text/template"
 	"time"
 
+	"github.com/deepakjois/podscript/internal/config"
+	"github.com/deepakjois/podscript/internal/llm"
+	"github.com/deepakjois/podscript/internal/normalize"
+	"github.com/deepakjois/podscript/internal/output"
+	"github.com/deepakjois/podscript/internal/stt"
 	"github.com/deepakjois/ytt"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 	"github.com/tmc/langchaingo/llms"
 )
 
@@ -31,15 +41,32 @@ Follow these steps to create a clean transcript:
 
 4. Break the text into logical paragraphs. Start a new paragraph when there's a shift in topic or speaker.
 
-5. Remove any unnecessary filler words, repetitions, or false starts.
+5. %s
 
-6. Maintain the original meaning and intent of the transcript. Do not remove any content even if it is unrelated to the main topic.
+6. %s%s
 
 
 Once you have completed these steps, provide the clean transcript within <transcript> and </transcript> tags. Ensure that the transcript is well-formatted, easy to read, 
 and accurately represents the original content of the video. Do not include any additional text in your response.`
 )
 
+const (
+	removeFillersInstruction = "Remove any unnecessary filler words, repetitions, or false starts."
+	languageInstruction      = "\n\n7. The captions are in %[1]s. Write the transcript in %[1]s, and do not translate it to any other language."
+	keepFillersInstruction   = "Keep all filler words (such as \"um\", \"uh\" and \"you know\"), repetitions and false starts exactly as spoken, so that the transcript remains verbatim."
+	keepContentInstruction   = "Maintain the original meaning and intent of the transcript. Do not remove any content even if it is unrelated to the main topic."
+	condenseInstruction      = "Maintain the original meaning and intent of the transcript, but condense it: shorten long-winded passages, and remove tangents, small talk and repeated points, so that the transcript is concise."
+	lightInstruction         = keepFillersInstruction + " Apart from correcting spelling, do not change, add, remove or reorder any words."
+	heavyInstruction         = removeFillersInstruction + " Also edit the text for readability: fix grammatical errors, and rephrase rambling or fragmented sentences into clear, concise ones, while keeping the speaker's voice."
+)
+
+// Clean levels
+const (
+	cleanLight  = "light"  // punctuation and spelling only
+	cleanMedium = "medium" // also removes fillers, unless keepFillers is set
+	cleanHeavy  = "heavy"  // also rewrites sentences for readability
+)
+
 var transcriptRegex = regexp.MustCompile(`(?s)<transcript>(.*?)</transcript>`)
 
 func extractTranscript(input string) string {
@@ -50,71 +77,418 @@ func extractTranscript(input string) string {
 	return ""
 }
 
+type cleanupOptions struct {
+	level        string
+	keepFillers  bool
+	condense     bool
+	language     string          // language of the captions, if not English
+	languageCode string          // code of the language of the captions, which selects a localized prompt
+	customPrompt string          // replaces the built-in prompt
+	settings     llm.Settings    // overrides the settings for the model in config
+	fallbacks    []llm.Model     // models to try in turn if the model is unavailable
+	preview      bool            // ask for confirmation after cleaning up the first chunk
+	normalize    []string        // kinds of normalization applied to the cleaned up transcript
+	protected    []protectedTerm // terms whose casing is restored after cleanup
+	verbose      bool            // print the word counts of each chunk
+	strict       bool            // fail instead of warning if content looks dropped
+	skipIfClean  bool            // keep chunks that already look clean instead of cleaning them up
+	maxChunks    int             // clean up only this many chunks from the start, if positive
+	timed        []timedWord     // words of the captions, to mark paragraphs with the time they start
+	showPrompt   bool            // print the prompt for the first chunk that is cleaned up
+}
+
 type transcriptCleaner struct {
-	modelOpt Model
+	modelOpt llm.Model
 	model    llms.Model
+	opts     cleanupOptions
+	pacers   map[llm.Model]*pacer // space out requests to each model to stay under its rate limit
+
+	// onChunk, if set, is called with each part of the transcript as soon as
+	// it is cleaned up
+	onChunk func(part int, text string) error
+	// onTruncated, if set, is called after the parts are cleaned up if only
+	// the first of them were, due to --max-chunks
+	onTruncated func(cleaned, total int) error
+}
+
+// jsonChunk is a line of output in the jsonl format.
+type jsonChunk struct {
+	Part int    `json:"part"`
+	Text string `json:"text"`
 }
 
-func newTranscriptCleaner(model Model) (*transcriptCleaner, error) {
-	llm, err := getModel(model)
+// jsonTruncated is the last line of output in the jsonl format when only the
+// first parts of the transcript are cleaned up.
+type jsonTruncated struct {
+	Truncated    bool `json:"truncated"`
+	CleanedParts int  `json:"cleaned_parts"`
+	TotalParts   int  `json:"total_parts"`
+}
+
+// truncationNote is appended to the cleaned up transcript when only the first
+// parts of it are cleaned up.
+func truncationNote(cleaned, total int) string {
+	return fmt.Sprintf("\n\n[truncated: cleaned %d of %d parts]\n", cleaned, total)
+}
+
+func newTranscriptCleaner(model llm.Model, opts cleanupOptions) (*transcriptCleaner, error) {
+	m, err := llm.New(model)
 	if err != nil {
 		return nil, err
 	}
-	return &transcriptCleaner{modelOpt: model, model: llm}, nil
+	models := append([]llm.Model{model}, opts.fallbacks...)
+	for _, m := range models {
+		if ignored := llm.IgnoredSettings(m, llm.ModelSettings(m, opts.settings)); len(ignored) > 0 {
+			fmt.Fprintf(os.Stderr, "warning: %s not supported for %s, ignoring\n", strings.Join(ignored, ", "), m)
+		}
+	}
+	pacers := newPacers(models, opts.settings)
+	return &transcriptCleaner{modelOpt: model, model: m, opts: opts, pacers: pacers}, nil
+}
+
+func (tc transcriptCleaner) prompt(chunk string) string {
+	if tc.opts.customPrompt != "" {
+		return customPrompt(tc.opts.customPrompt, chunk)
+	}
+	if p, ok := localizedPrompt(tc.opts.languageCode); ok {
+		var editing string
+		switch {
+		case tc.opts.level == cleanLight:
+			editing = p.keepFillers + p.light
+		case tc.opts.level == cleanHeavy:
+			editing = p.removeFillers + p.heavy
+		case tc.opts.keepFillers:
+			editing = p.keepFillers
+		default:
+			editing = p.removeFillers
+		}
+		content := p.keepContent
+		if tc.opts.condense {
+			content = p.condense
+		}
+		return fmt.Sprintf(p.template, chunk, editing, content)
+	}
+
+	var editing string
+	switch {
+	case tc.opts.level == cleanLight:
+		editing = lightInstruction
+	case tc.opts.level == cleanHeavy:
+		editing = heavyInstruction
+	case tc.opts.keepFillers:
+		editing = keepFillersInstruction
+	default:
+		editing = removeFillersInstruction
+	}
+	content := keepContentInstruction
+	if tc.opts.condense {
+		content = condenseInstruction
+	}
+	var language string
+	if tc.opts.language != "" {
+		language = fmt.Sprintf(languageInstruction, tc.opts.language)
+	}
+	return fmt.Sprintf(userPrompt, chunk, editing, content, language)
 }
 
-func (tc transcriptCleaner) cleanupTranscript(transcript string) (string, error) {
-	chunks, err := splitText(transcript, tc.modelOpt)
+// generate cleans up chunk using the model, falling back to each of the
+// fallback models in turn if a model is unavailable. It returns the response
+// along with the model that produced it.
+func (tc transcriptCleaner) generate(chunk string) (string, llm.Usage, llm.Model, error) {
+	modelOpt, model := tc.modelOpt, tc.model
+	for i := 0; ; i++ {
+		tc.pacers[modelOpt].wait()
+		start := time.Now()
+		prompt := tc.prompt(chunk)
+		response, usage, err := llm.Generate(
+			context.Background(),
+			model, prompt,
+			llm.CallOptions(modelOpt, llm.ModelSettings(modelOpt, tc.opts.settings))...,
+		)
+		if errors.Is(err, llm.ErrNoChoices) {
+			err = fmt.Errorf("%w from %s", err, llm.Provider(modelOpt))
+		}
+		paced := usage
+		if paced == (llm.Usage{}) {
+			// Not reported by the provider, or the request failed
+			paced = llm.EstimateUsage(llm.CountWords(prompt))
+		}
+		tc.pacers[modelOpt].record(start, paced)
+		if err == nil || !llm.Retryable(err) || i == len(tc.opts.fallbacks) {
+			return response, usage, modelOpt, err
+		}
+
+		fallback := tc.opts.fallbacks[i]
+		fmt.Printf("%s is unavailable (%v), falling back to %s…\n", modelOpt, err, fallback)
+		if model, err = llm.New(fallback); err != nil {
+			return "", llm.Usage{}, fallback, fmt.Errorf("failed to initialize model %s: %w", fallback, err)
+		}
+		modelOpt = fallback
+	}
+}
+
+// cleanupTranscript splits the transcript into parts and cleans them up. If
+// some of the parts fail, the rest are cleaned up regardless, and a
+// *chunksFailedError with the results of every part is returned along with
+// the transcript without the failed parts. Errors that would fail every part,
+// for e.g. an invalid API key, stop the cleanup instead.
+func (tc transcriptCleaner) cleanupTranscript(transcript string) (string, llm.ModelUsage, error) {
+	// Chunks must fit the output limit of every model that may be used
+	chunks, err := llm.SplitText(transcript, append([]llm.Model{tc.modelOpt}, tc.opts.fallbacks...)...)
 
 	if err != nil {
-		return "", fmt.Errorf("error splitting text: %w", err)
+		return "", nil, fmt.Errorf("error splitting text: %w", err)
+	}
+	total := len(chunks)
+	if tc.opts.maxChunks > 0 && len(chunks) > tc.opts.maxChunks {
+		fmt.Printf("cleaning up only the first %d of %d parts of the transcript (--max-chunks), the rest is left out\n", tc.opts.maxChunks, len(chunks))
+		chunks = chunks[:tc.opts.maxChunks]
 	}
 
-	var cleanedTranscript strings.Builder
+	results := make([]chunkResult, len(chunks))
 	for i, chunk := range chunks {
-		cleanedChunk, err := llms.GenerateFromSinglePrompt(
-			context.Background(),
-			tc.model, userPrompt+"\n\n"+chunk,
-			llms.WithMaxTokens(maxTokens[tc.modelOpt]),
+		results[i] = chunkResult{Part: i + 1, Input: chunk}
+	}
+	usage, err := tc.cleanupChunks(results)
+	if err != nil {
+		return "", nil, err
+	}
+
+	cleaned := joinChunks(results)
+	if len(chunks) < total {
+		// Mark the transcript, so that it isn't mistaken for the whole of it
+		cleaned += truncationNote(len(chunks), total)
+		if tc.onTruncated != nil {
+			if err := tc.onTruncated(len(chunks), total); err != nil {
+				return "", usage, fmt.Errorf("failed to write truncation note: %w", err)
+			}
+		}
+	}
+	if countFailed(results) > 0 {
+		return cleaned, usage, &chunksFailedError{results}
+	}
+	return cleaned, usage, nil
+}
+
+// cleanupChunks cleans up each of the chunks that is not done yet, recording
+// the cleaned up text or the error in the chunk. It returns the usage of each
+// model that cleaned up chunks.
+func (tc transcriptCleaner) cleanupChunks(chunks []chunkResult) (llm.ModelUsage, error) {
+	var totalUsage llm.ModelUsage
+	previewed := !tc.opts.preview
+	var offset int // of the chunk in the words of the captions
+	for i := range chunks {
+		c := &chunks[i]
+		from := offset
+		offset += llm.CountWords(c.Input)
+		if c.done() {
+			continue
+		}
+
+		var (
+			cleanedChunk string
+			model        llm.Model
+			err          error
 		)
+		skipped := tc.opts.skipIfClean && alreadyClean(c.Input)
+		if skipped {
+			cleanedChunk = strings.TrimSpace(c.Input)
+		} else {
+			if tc.opts.showPrompt {
+				// The prompt includes the captions, so it is only shown once
+				fmt.Fprintf(os.Stderr, "prompt for part %d/%d:\n%s\n\n", c.Part, len(chunks), tc.prompt(c.Input))
+				tc.opts.showPrompt = false
+			}
+			var usage llm.ModelUsage
+			cleanedChunk, usage, model, err = tc.generateChecked(c.Part, c.Input)
+			totalUsage = totalUsage.Merge(usage)
+			if abortsCleanup(err) {
+				return totalUsage, fmt.Errorf("part %d/%d: %w", c.Part, len(chunks), err)
+			}
+			if err == nil {
+				err = tc.checkFidelity(c.Part, c.Input, cleanedChunk)
+			}
+		}
 		if err != nil {
-			return "", fmt.Errorf("failed to process chunk: %w", err)
+			c.Error = err.Error()
+			fmt.Printf("failed to transcribe part %d/%d: %v\n", c.Part, len(chunks), err)
+			continue
+		}
+		if len(tc.opts.protected) > 0 {
+			if missing := missingTerms(c.Input, cleanedChunk, tc.opts.protected); len(missing) > 0 {
+				fmt.Printf("part %d: warning: protected terms missing from the cleaned up text: %s\n", c.Part, protectedTermsSummary(missing))
+			}
+			var restored int
+			if cleanedChunk, restored = restoreTerms(c.Input, cleanedChunk, tc.opts.protected); restored > 0 && tc.opts.verbose {
+				fmt.Printf("part %d: restored %d protected terms\n", c.Part, restored)
+			}
+		}
+		if len(tc.opts.normalize) > 0 {
+			cleanedChunk = normalize.Text(cleanedChunk, tc.opts.normalize)
+		}
+		if len(tc.opts.timed) > 0 {
+			cleanedChunk = markTimestamps(cleanedChunk, tc.opts.timed, from, offset)
+		}
+		c.Output, c.Error = cleanedChunk, ""
+		if tc.onChunk != nil {
+			if err := tc.onChunk(c.Part, cleanedChunk); err != nil {
+				return totalUsage, fmt.Errorf("failed to write chunk: %w", err)
+			}
 		}
-		cleanedChunk = extractTranscript(cleanedChunk)
-		cleanedTranscript.WriteString(cleanedChunk)
-		fmt.Printf("transcribed part %d/%d…\n", i+1, len(chunks))
+		if skipped {
+			fmt.Printf("part %d/%d is already clean, skipped cleanup…\n", c.Part, len(chunks))
+		} else if len(tc.opts.fallbacks) > 0 {
+			fmt.Printf("transcribed part %d/%d using %s…\n", c.Part, len(chunks), model)
+		} else {
+			fmt.Printf("transcribed part %d/%d…\n", c.Part, len(chunks))
+		}
+		if !previewed && i < len(chunks)-1 {
+			previewed = true
+			if err := confirmPreview(cleanedChunk, len(chunks)-1-i); err != nil {
+				return totalUsage, err
+			}
+		}
+	}
+	return totalUsage, nil
+}
+
+// abortsCleanup reports whether err means that the request itself was
+// rejected, for e.g. due to an invalid API key or model, so that the remaining
+// parts would fail too. Unusable or empty responses, for e.g. due to content
+// filtering, only fail the part, as do errors that may succeed later.
+func abortsCleanup(err error) bool {
+	var unusable unusableResponseError
+	return err != nil && !llm.Retryable(err) && !errors.As(err, &unusable) && !errors.Is(err, llm.ErrNoChoices)
+}
+
+// minFidelity is the lowest ratio of output to input words of a chunk that is
+// expected when the model is not allowed to condense the transcript. Removing
+// filler words alone rarely shortens a chunk by this much.
+const minFidelity = 0.7
+
+// checkFidelity compares the word counts of a chunk before and after cleanup,
+// to catch a model that summarized or dropped part of the chunk.
+func (tc transcriptCleaner) checkFidelity(part int, chunk, cleaned string) error {
+	in, out := llm.CountWords(chunk), llm.CountWords(cleaned)
+	if tc.opts.verbose {
+		fmt.Printf("part %d: %d words in, %d words out\n", part, in, out)
+	}
+	if tc.opts.condense || in == 0 || float64(out)/float64(in) >= minFidelity {
+		return nil
+	}
+	msg := fmt.Sprintf("part %d is %.0f%% shorter than the captions (%d words in, %d words out), the model may have dropped content",
+		part, 100*(1-float64(out)/float64(in)), in, out)
+	if tc.opts.strict {
+		return errors.New(msg)
 	}
-	return cleanedTranscript.String(), nil
+	fmt.Printf("warning: %s\n", msg)
+	return nil
+}
+
+func modelList() string {
+	models := make([]string, len(llm.Models))
+	for i, m := range llm.Models {
+		models[i] = string(m)
+	}
+	return strings.Join(models, ", ")
 }
 
 var Command = &cobra.Command{
 	Use:   "ytt <youtube_url>",
 	Short: "Generate cleaned up transcript from YouTube autogenerated captions using an LLM",
-	Args:  cobra.ExactArgs(1),
+	Args: func(cmd *cobra.Command, args []string) error {
+		// A retry reads the captions from the file of failed chunks
+		if retry, _ := cmd.Flags().GetString("retry-failed-chunks"); retry != "" {
+			return cobra.NoArgs(cmd, args)
+		}
+		return cobra.ExactArgs(1)(cmd, args)
+	},
 	PreRunE: func(cmd *cobra.Command, args []string) error {
+		switch prefer, _ := cmd.Flags().GetString("prefer"); prefer {
+		case preferManual, preferAuto:
+		default:
+			return fmt.Errorf("invalid --prefer: must be one of %s, %s", preferManual, preferAuto)
+		}
+
+		if backend, _ := cmd.Flags().GetString("stt-fallback"); !validSTTBackend(backend) {
+			return fmt.Errorf("invalid --stt-fallback: must be one of %s", strings.Join(sttBackends, ", "))
+		}
+
 		raw, _ := cmd.Flags().GetBool("raw")
 		if raw {
 			return nil
 		}
 
+		compare, _ := cmd.Flags().GetStringSlice("compare")
+		for _, model := range compare {
+			if !llm.Supported(llm.Model(model)) {
+				return fmt.Errorf("invalid model to compare: %s", model)
+			}
+		}
+
+		fallbacks, _ := cmd.Flags().GetStringArray("fallback-model")
+		for _, model := range fallbacks {
+			if !llm.Supported(llm.Model(model)) {
+				return fmt.Errorf("invalid fallback model: %s", model)
+			}
+		}
+
 		model, _ := cmd.Flags().GetString("model")
-		switch model {
-		case string(ChatGPT4o), string(ChatGpt4oMini), string(Claude3Dot5Sonnet20240620), string(GroqLlama3170B):
-			return nil
+		if !llm.Supported(llm.Model(model)) {
+			return fmt.Errorf("invalid model: must be one of %s", modelList())
+		}
+
+		if topP, _ := cmd.Flags().GetFloat64("top-p"); topP < 0 || topP > 1 {
+			return errors.New("invalid --top-p: must be between 0 and 1")
+		}
+
+		if maxChunks, _ := cmd.Flags().GetInt("max-chunks"); maxChunks < 0 {
+			return errors.New("invalid --max-chunks: must not be negative")
+		}
+
+		switch level, _ := cmd.Flags().GetString("clean-level"); level {
+		case cleanLight, cleanMedium, cleanHeavy:
 		default:
-			return fmt.Errorf("invalid model: must be one of %s, %s or %s", ChatGpt4oMini, ChatGPT4o, Claude3Dot5Sonnet20240620)
+			return fmt.Errorf("invalid clean level: must be one of %s, %s, %s", cleanLight, cleanMedium, cleanHeavy)
+		}
+
+		kinds, _ := cmd.Flags().GetStringSlice("normalize")
+		for _, kind := range kinds {
+			if !normalize.Valid(kind) {
+				return fmt.Errorf("invalid normalization: must be one of %s", strings.Join(normalize.Kinds, ", "))
+			}
+		}
+
+		if subtitles, _ := cmd.Flags().GetString("subtitles"); !stt.ValidSubtitles(subtitles) {
+			return fmt.Errorf("invalid subtitle format: must be one of %s, %s", stt.SubtitlesSRT, stt.SubtitlesVTT)
 		}
+
+		format, _ := cmd.Flags().GetString("format")
+		if format != stt.FormatText && format != stt.FormatJSONLines && format != stt.FormatHTML {
+			return fmt.Errorf("invalid format: must be one of %s, %s, %s", stt.FormatText, stt.FormatJSONLines, stt.FormatHTML)
+		}
+		return nil
 	},
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if retry, _ := cmd.Flags().GetString("retry-failed-chunks"); retry != "" {
+			return retryFailedChunks(cmd, retry)
+		}
+
 		raw, _ := cmd.Flags().GetBool("raw")
 
+		var tmpl *template.Template
+		templateFile, _ := cmd.Flags().GetString("template")
+		if templateFile != "" {
+			var err error
+			if tmpl, err = stt.ReadTemplate(templateFile); err != nil {
+				return err
+			}
+		}
+
 		folder, _ := cmd.Flags().GetString("path")
 		suffix, _ := cmd.Flags().GetString("suffix")
-		if folder != "" {
-			fi, err := os.Stat(folder)
-			if err != nil || !fi.IsDir() {
-				return fmt.Errorf("path not found: %s", folder)
-			}
+		if err := output.CheckDir(folder); err != nil {
+			return err
 		}
 		timestamp := time.Now().Format("2006-01-02-150405")
 		var filenameSuffix string
@@ -127,22 +501,41 @@ var Command = &cobra.Command{
 		// Extract Transcript
 		videoID, err := ytt.ExtractVideoID(args[0])
 		if err != nil {
-			return fmt.Errorf("failed to extract video ID: %w", err)
+			return fmt.Errorf("failed to extract video ID from %s: %w", args[0], classifyVideoError("", err))
 		}
 
-		transcriptList, err := ytt.ListTranscripts(videoID)
+		language, _ := cmd.Flags().GetString("language")
+		prefer, _ := cmd.Flags().GetString("prefer")
+		transcript, entries, err := fetchCaptions(videoID, language, prefer)
+		sttFallback, _ := cmd.Flags().GetString("stt-fallback")
+		transcribed := false
+		if err != nil && sttFallback != "" && noCaptions(err) {
+			// Downloading and transcribing the audio is billed, so say so
+			fmt.Printf("no captions found (%v), downloading the audio and transcribing it with %s, which is billed by %s\n", err, sttFallback, sttFallback)
+			transcript, entries, err = transcribeAudio(videoID, language, sttFallback)
+			transcribed = err == nil
+		}
 		if err != nil {
-			return fmt.Errorf("failed to list transcripts: %w", err)
+			return err
+		}
+		switch {
+		case transcribed:
+			fmt.Printf("transcribed the audio with %s, using the transcript as %s captions\n", sttFallback, transcript.LanguageCode)
+		case transcript.IsGenerated:
+			fmt.Printf("using auto-generated %s captions\n", transcript.LanguageCode)
+		default:
+			fmt.Printf("using manually created %s captions\n", transcript.LanguageCode)
+		}
+		if !transcribed && language == "" && transcript.LanguageCode != "en" {
+			fmt.Printf("English captions not found, using %s captions\n", transcript.Language)
 		}
 
-		transcript, err := transcriptList.FindTranscript("en")
-		if err != nil {
-			return fmt.Errorf("failed to find English transcript: %w", err)
+		for i := range entries {
+			entries[i].Text = sanitizeCaption(entries[i].Text)
 		}
 
-		entries, err := transcript.Fetch()
-		if err != nil {
-			return fmt.Errorf("failed to fetch transcript: %w", err)
+		if dedupe, _ := cmd.Flags().GetBool("dedupe-captions"); dedupe && transcript.IsGenerated {
+			entries = dedupeCaptions(entries)
 		}
 
 		var transcriptTxt strings.Builder
@@ -151,43 +544,371 @@ var Command = &cobra.Command{
 		}
 
 		rawTranscriptFilename := path.Join(folder, fmt.Sprintf("raw_transcript_%s.txt", filenameSuffix))
-		if err = os.WriteFile(rawTranscriptFilename, []byte(transcriptTxt.String()), 0644); err != nil {
+		if err = output.WriteFile(rawTranscriptFilename, []byte(transcriptTxt.String())); err != nil {
 			return fmt.Errorf("failed to write raw transcript: %w", err)
 		}
 		fmt.Printf("wrote raw autogenerated captions to %s\n", rawTranscriptFilename)
 
+		segments := make([]stt.Segment, len(entries))
+		for i, entry := range entries {
+			segments[i] = stt.Segment{Start: entry.Start, End: entry.Start + entry.Duration, Text: entry.Text}
+		}
+		if subtitles, _ := cmd.Flags().GetString("subtitles"); subtitles != "" {
+			var sb strings.Builder
+			if err = stt.WriteSubtitles(&sb, subtitles, segments); err != nil {
+				return err
+			}
+			subtitlesFilename := path.Join(folder, fmt.Sprintf("subtitles_%s.%s", filenameSuffix, subtitles))
+			if err = output.WriteFile(subtitlesFilename, []byte(sb.String())); err != nil {
+				return fmt.Errorf("failed to write subtitles: %w", err)
+			}
+			fmt.Printf("wrote captions as subtitles to %s\n", subtitlesFilename)
+		}
+
 		// Stop if only raw transcript required
 		if raw {
 			return nil
 		}
 
 		// Initialize API client
+		setOpenAIBilling(cmd)
+		opts, err := cleanupOptionsFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+		if !strings.HasPrefix(transcript.LanguageCode, "en") {
+			opts.language = strings.TrimSuffix(transcript.Language, " (auto-generated)")
+		}
+		opts.languageCode = transcript.LanguageCode
+		if timestamps, _ := cmd.Flags().GetBool("timestamps"); timestamps {
+			opts.timed = timedWords(entries)
+		}
+
+		var models []llm.Model
+		compare, _ := cmd.Flags().GetStringSlice("compare")
+		for _, m := range compare {
+			models = append(models, llm.Model(m))
+		}
 		m, _ := cmd.Flags().GetString("model")
-		model := Model(m)
-		tc, err := newTranscriptCleaner(model)
+		model := llm.Model(m)
+		if len(models) == 0 {
+			models = append(models, model)
+		}
+
+		if yes, _ := cmd.Flags().GetBool("yes"); !yes {
+			threshold, _ := cmd.Flags().GetFloat64("confirm-over")
+			text := transcriptTxt.String()
+			if opts.maxChunks > 0 {
+				// Only the first chunks are cleaned up and paid for
+				chunks, err := llm.SplitText(text, append([]llm.Model{model}, opts.fallbacks...)...)
+				if err == nil && len(chunks) > opts.maxChunks {
+					text = strings.Join(chunks[:opts.maxChunks], " ")
+				}
+			}
+			if err := confirmCost(models, text, threshold); err != nil {
+				return err
+			}
+		}
+
+		if len(compare) > 0 {
+			return compareModels(models, transcriptTxt.String(), opts, folder, filenameSuffix)
+		}
+
+		if apiKey, _ := cmd.Flags().GetString("api-key"); apiKey != "" {
+			config.SetAPIKey(llm.Provider(model), apiKey)
+		}
+		tc, err := newTranscriptCleaner(model, opts)
 		if err != nil {
 			return fmt.Errorf("failed to initialize model %s: %v", model, err)
 		}
 
-		cleanedTranscriptTxt, err := tc.cleanupTranscript(transcriptTxt.String())
-		if err != nil {
+		format, _ := cmd.Flags().GetString("format")
+		cleanedTranscriptFilename := path.Join(folder, fmt.Sprintf("cleaned_transcript_%s.%s", filenameSuffix, stt.Extension(format)))
+
+		// Text and JSON Lines are written as each part is cleaned up, so that
+		// the parts cleaned up so far are kept if podscript is interrupted.
+		// HTML pages are written once complete.
+		var (
+			cleanedTranscriptTxt string
+			usage                llm.ModelUsage
+			f                    *output.File
+		)
+		if format == stt.FormatHTML {
+			cleanedTranscriptTxt, usage, err = tc.cleanupTranscript(transcriptTxt.String())
+		} else {
+			if f, err = output.Create(cleanedTranscriptFilename); err != nil {
+				return fmt.Errorf("failed to create cleaned transcript: %w", err)
+			}
+
+			w := bufio.NewWriter(f)
+			enc := json.NewEncoder(w)
+			tc.onChunk = func(part int, text string) error {
+				var err error
+				if format == stt.FormatJSONLines {
+					err = enc.Encode(jsonChunk{Part: part, Text: text})
+				} else {
+					_, err = w.WriteString(text)
+				}
+				if err != nil {
+					return err
+				}
+				return w.Flush()
+			}
+			tc.onTruncated = func(cleaned, total int) error {
+				var err error
+				if format == stt.FormatJSONLines {
+					err = enc.Encode(jsonTruncated{Truncated: true, CleanedParts: cleaned, TotalParts: total})
+				} else {
+					_, err = w.WriteString(truncationNote(cleaned, total))
+				}
+				if err != nil {
+					return err
+				}
+				return w.Flush()
+			}
+			cleanedTranscriptTxt, usage, err = tc.cleanupTranscript(transcriptTxt.String())
+		}
+
+		var failed *chunksFailedError
+		if err != nil && !errors.As(err, &failed) {
+			if f != nil {
+				f.Close()
+			}
 			return fmt.Errorf("failed to transcribe: %w", err)
 		}
 
-		cleanedTranscriptFilename := path.Join(folder, fmt.Sprintf("cleaned_transcript_%s.txt", filenameSuffix))
-		if err = os.WriteFile(cleanedTranscriptFilename, []byte(cleanedTranscriptTxt), 0644); err != nil {
+		var title string
+		if format == stt.FormatHTML || tmpl != nil {
+			title = videoTitle(videoID)
+		}
+
+		if format == stt.FormatHTML {
+			var page strings.Builder
+			if err := stt.WriteHTMLText(&page, title, cleanedTranscriptTxt); err != nil {
+				return err
+			}
+			if err := output.WriteFile(cleanedTranscriptFilename, []byte(page.String())); err != nil {
+				return fmt.Errorf("failed to write cleaned transcript: %w", err)
+			}
+		} else if err := f.Close(); err != nil {
 			return fmt.Errorf("failed to write cleaned transcript: %w", err)
 		}
 		fmt.Printf("wrote cleaned up transcripts to %s\n", cleanedTranscriptFilename)
+		printUsage(usage)
+
+		if tmpl != nil {
+			url := fmt.Sprintf(watchURL, videoID)
+			total := usage.Total()
+			data := stt.TemplateData{
+				Title:      title,
+				URL:        url,
+				Model:      string(model),
+				Date:       time.Now(),
+				Transcript: cleanedTranscriptTxt,
+				Segments:   segments,
+				Usage:      &total,
+				Cost:       usage.Cost(),
+			}
+			templateFilename := path.Join(folder, fmt.Sprintf("templated_transcript_%s.%s", filenameSuffix, stt.TemplateExtension(templateFile)))
+			if err := stt.WriteTemplate(templateFilename, tmpl, data); err != nil {
+				return err
+			}
+			fmt.Printf("wrote templated transcript to %s\n", templateFilename)
+		}
+
+		if failed != nil {
+			run := &failedRun{Source: args[0], Language: opts.language, LanguageCode: opts.languageCode, Format: format, Output: cleanedTranscriptFilename, Chunks: failed.chunks}
+			failedFilename := path.Join(folder, fmt.Sprintf("failed_chunks_%s.json", filenameSuffix))
+			if err := run.write(failedFilename); err != nil {
+				return err
+			}
+			return fmt.Errorf("failed to transcribe: %w, run again with --retry-failed-chunks %s to retry them", failed, failedFilename)
+		}
 		return nil
 	},
 }
 
+// setOpenAIBilling sets the OpenAI organization and project from flags.
+func setOpenAIBilling(cmd *cobra.Command) {
+	if org, _ := cmd.Flags().GetString("openai-org"); org != "" {
+		viper.Set("openai_organization", org)
+	}
+	if project, _ := cmd.Flags().GetString("openai-project"); project != "" {
+		viper.Set("openai_project", project)
+	}
+}
+
+// cleanupOptionsFromFlags returns the cleanup options set by flags. The
+// language of the captions is set by the caller.
+func cleanupOptionsFromFlags(cmd *cobra.Command) (cleanupOptions, error) {
+	keepFillers, _ := cmd.Flags().GetBool("keep-fillers")
+	if removeFillers, _ := cmd.Flags().GetBool("remove-fillers"); !removeFillers {
+		keepFillers = true
+	}
+	level, _ := cmd.Flags().GetString("clean-level")
+	condense, _ := cmd.Flags().GetBool("allow-condensing")
+	opts := cleanupOptions{level: level, keepFillers: keepFillers, condense: condense}
+	fallbacks, _ := cmd.Flags().GetStringArray("fallback-model")
+	for _, m := range fallbacks {
+		opts.fallbacks = append(opts.fallbacks, llm.Model(m))
+	}
+	if cmd.Flags().Changed("temperature") {
+		temperature, _ := cmd.Flags().GetFloat64("temperature")
+		opts.settings.Temperature = &temperature
+	}
+	if cmd.Flags().Changed("tokens-per-minute") {
+		tpm, _ := cmd.Flags().GetInt("tokens-per-minute")
+		opts.settings.TokensPerMinute = &tpm
+	}
+	if cmd.Flags().Changed("top-p") {
+		topP, _ := cmd.Flags().GetFloat64("top-p")
+		opts.settings.TopP = &topP
+	}
+	opts.settings.MaxTokens, _ = cmd.Flags().GetInt("max-tokens")
+	opts.preview, _ = cmd.Flags().GetBool("preview")
+	opts.normalize, _ = cmd.Flags().GetStringSlice("normalize")
+	opts.verbose, _ = cmd.Flags().GetBool("verbose")
+	opts.strict, _ = cmd.Flags().GetBool("strict-fidelity")
+	opts.skipIfClean, _ = cmd.Flags().GetBool("skip-if-clean")
+	opts.maxChunks, _ = cmd.Flags().GetInt("max-chunks")
+	opts.showPrompt, _ = cmd.Flags().GetBool("show-prompt")
+	if promptFile, _ := cmd.Flags().GetString("system-prompt-file"); promptFile != "" {
+		prompt, err := readCustomPrompt(promptFile)
+		if err != nil {
+			return opts, err
+		}
+		opts.customPrompt = prompt
+	}
+	if protect, _ := cmd.Flags().GetString("protect-terms"); protect != "" {
+		terms, err := stt.ReadVocabulary(protect)
+		if err != nil {
+			return opts, fmt.Errorf("failed to read protected terms: %w", err)
+		}
+		opts.protected = compileProtectedTerms(terms)
+	}
+	return opts, nil
+}
+
+// retryFailedChunks cleans up the failed parts of a previous run again, and
+// rewrites its cleaned up transcript with them spliced in.
+func retryFailedChunks(cmd *cobra.Command, failedFilename string) error {
+	run, err := readFailedRun(failedFilename)
+	if err != nil {
+		return err
+	}
+
+	var failedInput strings.Builder
+	for _, c := range run.Chunks {
+		if !c.done() {
+			failedInput.WriteString(c.Input)
+		}
+	}
+	fmt.Printf("retrying %d of %d parts\n", countFailed(run.Chunks), len(run.Chunks))
+
+	m, _ := cmd.Flags().GetString("model")
+	model := llm.Model(m)
+	if yes, _ := cmd.Flags().GetBool("yes"); !yes {
+		threshold, _ := cmd.Flags().GetFloat64("confirm-over")
+		if err := confirmCost([]llm.Model{model}, failedInput.String(), threshold); err != nil {
+			return err
+		}
+	}
+
+	setOpenAIBilling(cmd)
+	if apiKey, _ := cmd.Flags().GetString("api-key"); apiKey != "" {
+		config.SetAPIKey(llm.Provider(model), apiKey)
+	}
+	opts, err := cleanupOptionsFromFlags(cmd)
+	if err != nil {
+		return err
+	}
+	opts.language, opts.languageCode = run.Language, run.LanguageCode
+	tc, err := newTranscriptCleaner(model, opts)
+	if err != nil {
+		return fmt.Errorf("failed to initialize model %s: %v", model, err)
+	}
+	usage, err := tc.cleanupChunks(run.Chunks)
+	if err != nil {
+		return fmt.Errorf("failed to transcribe: %w", err)
+	}
+	printUsage(usage)
+
+	if err = writeCleaned(run.Output, run.Format, run.Source, run.Chunks); err != nil {
+		return fmt.Errorf("failed to write cleaned transcript: %w", err)
+	}
+	fmt.Printf("wrote cleaned up transcripts to %s\n", run.Output)
+
+	if countFailed(run.Chunks) > 0 {
+		if err = run.write(failedFilename); err != nil {
+			return err
+		}
+		return fmt.Errorf("failed to transcribe: %w, run again with --retry-failed-chunks %s to retry them", &chunksFailedError{run.Chunks}, failedFilename)
+	}
+	return os.Remove(failedFilename)
+}
+
 func init() {
 	Command.Flags().StringP("path", "p", "", "save raw and cleaned up transcripts to path")
 	Command.Flags().StringP("suffix", "s", "", "append suffix to filenames")
 	Command.Flags().BoolP("raw", "r", false, "download raw transcript, don't cleanup using LLM")
-	Command.Flags().StringP("model", "m", string(ChatGpt4oMini), fmt.Sprintf("use model - one of %s (default if ommitted), %s, %s or %s", ChatGpt4oMini, ChatGPT4o, Claude3Dot5Sonnet20240620, GroqLlama3170B))
+	Command.Flags().StringP("model", "m", string(llm.ChatGpt4oMini), fmt.Sprintf("use model - one of %s", modelList()))
+	Command.Flags().Bool("keep-fillers", false, "keep filler words, repetitions and false starts for a verbatim transcript")
+	Command.Flags().Bool("remove-fillers", true, "remove filler words, repetitions and false starts for a readable transcript")
+	Command.Flags().String("clean-level", cleanMedium, fmt.Sprintf("how much to edit the captions - one of %s (punctuation and spelling only), %s, %s (rewrite for readability)", cleanLight, cleanMedium, cleanHeavy))
+	Command.Flags().Bool("allow-condensing", false, "allow the LLM to condense the transcript by removing tangents and repetition")
+	Command.Flags().StringP("language", "l", "", "language code of the captions to use, for e.g. es (default en, falling back to any available language)")
+	Command.Flags().String("prefer", preferManual, fmt.Sprintf("kind of captions to use when a language has both - %s or %s", preferManual, preferAuto))
+	Command.Flags().String("stt-fallback", "", fmt.Sprintf("if the video has no captions, download its audio and transcribe it with this STT API - one of %s (billed by the provider)", strings.Join(sttBackends, ", ")))
+	Command.Flags().Bool("dedupe-captions", false, "remove repeated words from overlapping auto-generated caption segments")
+	Command.Flags().String("api-key", "", "API key for the provider of the selected model (overrides environment and config)")
+	Command.Flags().String("openai-org", "", "OpenAI organization ID used for billing (overrides openai_organization in config)")
+	Command.Flags().String("openai-project", "", "OpenAI project ID used for billing (overrides openai_project in config)")
+	Command.Flags().StringSlice("compare", nil, "clean up the transcript with each of the comma-separated models and compare the results")
+	Command.Flags().StringArray("fallback-model", nil, "model to use if the previous model is overloaded or rate limited (can be repeated)")
+	Command.Flags().Float64("temperature", 0, "sampling temperature of the model (overrides temperature in the config for the model)")
+	Command.Flags().Float64("top-p", 0, "nucleus sampling probability of the model, between 0 and 1, Anthropic models only (overrides top_p in the config for the model)")
+	Command.Flags().Int("tokens-per-minute", 0, "pace requests to stay under this many tokens per minute, 0 to not pace (overrides tokens_per_minute in the config for the model)")
+	Command.Flags().Int("max-tokens", 0, "maximum number of output tokens per request (overrides max_tokens in the config for the model)")
+	Command.Flags().Float64("confirm-over", 1.0, "ask for confirmation if the estimated cost of cleanup exceeds this amount in USD")
+	Command.Flags().BoolP("yes", "y", false, "skip the cost confirmation")
+	Command.Flags().String("format", stt.FormatText, fmt.Sprintf("output format of the cleaned up transcript - one of %s, %s, %s", stt.FormatText, stt.FormatJSONLines, stt.FormatHTML))
 	Command.MarkFlagsMutuallyExclusive("raw", "model")
+	Command.MarkFlagsMutuallyExclusive("raw", "compare")
+	Command.MarkFlagsMutuallyExclusive("model", "compare")
+	Command.MarkFlagsMutuallyExclusive("fallback-model", "compare")
+	Command.MarkFlagsMutuallyExclusive("raw", "format")
+	Command.MarkFlagsMutuallyExclusive("format", "compare")
+	Command.MarkFlagsMutuallyExclusive("api-key", "compare")
+	Command.Flags().Bool("preview", false, "print the cleaned up first part of the transcript and ask whether to continue with the rest")
+	Command.Flags().String("subtitles", "", fmt.Sprintf("also write the captions as subtitles - one of %s, %s", stt.SubtitlesSRT, stt.SubtitlesVTT))
+	Command.Flags().String("system-prompt-file", "", "file with a prompt that replaces the built-in cleanup prompt ({{captions}} is replaced with the captions, which are appended otherwise)")
+	Command.Flags().String("template", "", "also write the cleaned up transcript using this Go text/template, for e.g. to add front matter (see README)")
+	Command.Flags().Bool("show-prompt", false, "print the prompt sent to the model for the first part of the transcript to stderr")
+	Command.Flags().String("protect-terms", "", "file with terms (one per line), for e.g. product names and acronyms, whose exact casing is restored if changed during cleanup")
+	Command.Flags().StringSlice("normalize", nil, fmt.Sprintf("normalize the cleaned up transcript - comma-separated list of %s", strings.Join(normalize.Kinds, ", ")))
+	Command.Flags().BoolP("verbose", "v", false, "print the word counts of each part of the transcript before and after cleanup")
+	Command.Flags().Bool("strict-fidelity", false, "fail if a part of the cleaned up transcript is much shorter than the captions, instead of warning")
+	Command.Flags().Bool("timestamps", false, "start each paragraph of the cleaned up transcript with the time it is spoken in the video, for e.g. [12:34]")
+	Command.Flags().Int("max-chunks", 0, "clean up only the first N parts of the transcript, for e.g. to try out options on a long video (0 for all)")
+	Command.Flags().Bool("skip-if-clean", false, "keep parts of the captions that are already punctuated and capitalized, for e.g. manual captions, instead of cleaning them up")
+	Command.Flags().String("retry-failed-chunks", "", "clean up only the failed parts recorded in this file by a previous run, and splice them into its transcript")
+	Command.MarkFlagsMutuallyExclusive("preview", "compare")
+	Command.MarkFlagsMutuallyExclusive("retry-failed-chunks", "raw")
+	Command.MarkFlagsMutuallyExclusive("retry-failed-chunks", "stt-fallback")
+	Command.MarkFlagsMutuallyExclusive("retry-failed-chunks", "subtitles")
+	Command.MarkFlagsMutuallyExclusive("retry-failed-chunks", "template")
+	Command.MarkFlagsMutuallyExclusive("raw", "template")
+	Command.MarkFlagsMutuallyExclusive("compare", "template")
+	Command.MarkFlagsMutuallyExclusive("retry-failed-chunks", "compare")
+	Command.MarkFlagsMutuallyExclusive("retry-failed-chunks", "max-chunks")
+	Command.MarkFlagsMutuallyExclusive("retry-failed-chunks", "timestamps")
+	Command.MarkFlagsMutuallyExclusive("raw", "timestamps")
+	Command.MarkFlagsMutuallyExclusive("strict-fidelity", "allow-condensing")
+	Command.MarkFlagsMutuallyExclusive("raw", "normalize")
+	Command.MarkFlagsMutuallyExclusive("raw", "protect-terms")
+	Command.MarkFlagsMutuallyExclusive("raw", "system-prompt-file")
+	Command.MarkFlagsMutuallyExclusive("keep-fillers", "remove-fillers")
+	Command.MarkFlagsMutuallyExclusive("clean-level", "keep-fillers")
+	Command.MarkFlagsMutuallyExclusive("clean-level", "remove-fillers")
 
 }
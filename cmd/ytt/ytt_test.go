@@ -0,0 +1,79 @@
+package ytt
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/deepakjois/podscript/internal/llm"
+	"github.com/tmc/langchaingo/llms"
+)
+
+// scriptedModel responds to each call with the next of its responses, or
+// with the error if it is not nil.
+type scriptedModel struct {
+	responses []scriptedResponse
+	calls     int
+}
+
+type scriptedResponse struct {
+	text string
+	err  error
+}
+
+func (m *scriptedModel) GenerateContent(context.Context, []llms.MessageContent, ...llms.CallOption) (*llms.ContentResponse, error) {
+	r := m.responses[m.calls%len(m.responses)]
+	m.calls++
+	if r.err != nil {
+		return nil, r.err
+	}
+	return &llms.ContentResponse{Choices: []*llms.ContentChoice{{Content: r.text}}}, nil
+}
+
+func (m *scriptedModel) Call(context.Context, string, ...llms.CallOption) (string, error) {
+	return "", errors.New("not implemented")
+}
+
+func TestCleanupChunksFailures(t *testing.T) {
+	const (
+		input   = "so this is the first part of the captions we are testing"
+		cleaned = "<transcript>So this is the first part of the captions we are testing.</transcript>"
+	)
+	tests := []struct {
+		name      string
+		err       error
+		wantAbort bool
+	}{
+		{name: "empty openai response", err: errors.New("empty response"), wantAbort: false},
+		{name: "no choices", err: llm.ErrNoChoices, wantAbort: false},
+		{name: "rate limited", err: errors.New("API returned unexpected status code: 429: slow down"), wantAbort: false},
+		{name: "invalid key", err: errors.New("API returned unexpected status code: 401: invalid key"), wantAbort: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			model := &scriptedModel{responses: []scriptedResponse{{err: tt.err}, {text: cleaned}}}
+			tc := transcriptCleaner{modelOpt: llm.ChatGpt4oMini, model: model}
+			chunks := []chunkResult{{Part: 1, Input: input}, {Part: 2, Input: input}}
+
+			_, err := tc.cleanupChunks(chunks)
+			if tt.wantAbort {
+				if err == nil {
+					t.Fatal("cleanupChunks() error = nil, want the run to be aborted")
+				}
+				if model.calls != 1 {
+					t.Errorf("cleanupChunks() made %d calls after aborting, want 1", model.calls)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("cleanupChunks() error = %v, want only the part to fail", err)
+			}
+			if chunks[0].done() || chunks[0].Error == "" {
+				t.Errorf("part 1 = %+v, want it recorded as failed", chunks[0])
+			}
+			if !chunks[1].done() {
+				t.Errorf("part 2 = %+v, want it cleaned up", chunks[1])
+			}
+		})
+	}
+}
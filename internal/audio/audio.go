@@ -0,0 +1,55 @@
+// Package audio provides helpers to inspect and process audio files using
+// ffmpeg and ffprobe.
+package audio
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrFFprobeNotFound is returned when ffprobe is not installed.
+var ErrFFprobeNotFound = errors.New("ffprobe not found, please install ffmpeg")
+
+// Duration returns the duration of the audio file at input, which can be a
+// local path or an http(s) URL. For URLs, ffprobe only fetches as much of the
+// file as it needs to read the duration from its metadata.
+func Duration(ctx context.Context, input string) (time.Duration, error) {
+	if _, err := exec.LookPath("ffprobe"); err != nil {
+		return 0, ErrFFprobeNotFound
+	}
+
+	out, err := exec.CommandContext(ctx, "ffprobe",
+		"-v", "error",
+		"-show_entries", "format=duration",
+		"-of", "default=noprint_wrappers=1:nokey=1",
+		input,
+	).Output()
+	if err != nil {
+		return 0, fmt.Errorf("ffprobe failed: %w", err)
+	}
+
+	seconds, err := strconv.ParseFloat(strings.TrimSpace(string(out)), 64)
+	if err != nil {
+		return 0, fmt.Errorf("could not parse duration %q: %w", strings.TrimSpace(string(out)), err)
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// PrintEstimate prints the duration of the audio file at input, and the
+// expected cost of transcribing it at costPerMinute (in USD). If the duration
+// cannot be determined, it is reported as unknown.
+func PrintEstimate(ctx context.Context, input string, costPerMinute float64) {
+	d, err := Duration(ctx, input)
+	if err != nil {
+		fmt.Printf("duration: unknown (%v)\n", err)
+		fmt.Printf("estimated cost: unknown ($%.4f per minute)\n", costPerMinute)
+		return
+	}
+	fmt.Printf("duration: %s\n", d.Round(time.Second))
+	fmt.Printf("estimated cost: $%.4f ($%.4f per minute)\n", d.Minutes()*costPerMinute, costPerMinute)
+}
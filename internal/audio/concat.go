@@ -0,0 +1,122 @@
+package audio
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// streamParams returns the codec, sample rate and channel count of the first
+// audio stream of input, which must match for files to be joined without
+// re-encoding.
+func streamParams(ctx context.Context, input string) (string, error) {
+	if _, err := exec.LookPath("ffprobe"); err != nil {
+		return "", ErrFFprobeNotFound
+	}
+	out, err := exec.CommandContext(ctx, "ffprobe",
+		"-v", "error",
+		"-select_streams", "a:0",
+		"-show_entries", "stream=codec_name,sample_rate,channels",
+		"-of", "csv=p=0",
+		input,
+	).Output()
+	if err != nil {
+		return "", fmt.Errorf("ffprobe failed on %s: %w", input, err)
+	}
+	params := strings.TrimSpace(string(out))
+	if params == "" {
+		return "", fmt.Errorf("no audio stream found in %s", input)
+	}
+	return params, nil
+}
+
+// Concat joins the audio files in inputs, in order, into a single temporary
+// file, and returns its path, which the caller must remove. Files with the
+// same codec, sample rate and channel count are joined without re-encoding.
+// Otherwise they are re-encoded to MP3.
+func Concat(ctx context.Context, inputs []string) (string, error) {
+	if _, err := exec.LookPath("ffmpeg"); err != nil {
+		return "", ErrFFmpegNotFound
+	}
+
+	compatible := true
+	var first string
+	for i, input := range inputs {
+		params, err := streamParams(ctx, input)
+		if err != nil {
+			return "", err
+		}
+		if i == 0 {
+			first = params
+		} else if params != first {
+			compatible = false
+		}
+	}
+
+	ext := filepath.Ext(inputs[0])
+	if !compatible {
+		ext = ".mp3"
+	}
+	f, err := os.CreateTemp("", "podscript-*"+ext)
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	output := f.Name()
+	f.Close()
+
+	if compatible {
+		err = concatCopy(ctx, inputs, output)
+	} else {
+		err = concatEncode(ctx, inputs, output)
+	}
+	if err != nil {
+		os.Remove(output)
+		return "", err
+	}
+	return output, nil
+}
+
+// concatCopy joins inputs using the concat demuxer, which copies the audio as
+// is.
+func concatCopy(ctx context.Context, inputs []string, output string) error {
+	var list strings.Builder
+	for _, input := range inputs {
+		abs, err := filepath.Abs(input)
+		if err != nil {
+			return fmt.Errorf("invalid audio file: %s", input)
+		}
+		fmt.Fprintf(&list, "file '%s'\n", strings.ReplaceAll(abs, "'", `'\''`))
+	}
+
+	f, err := os.CreateTemp("", "podscript-*.txt")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(f.Name())
+	if _, err = f.WriteString(list.String()); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write file list: %w", err)
+	}
+	if err = f.Close(); err != nil {
+		return fmt.Errorf("failed to write file list: %w", err)
+	}
+
+	return runFFmpeg(ctx, "-y", "-v", "error", "-f", "concat", "-safe", "0", "-i", f.Name(), "-map", "0:a", "-c", "copy", output)
+}
+
+// concatEncode joins inputs using the concat filter, re-encoding the audio to
+// MP3 so that files with different formats can be joined.
+func concatEncode(ctx context.Context, inputs []string, output string) error {
+	args := []string{"-y", "-v", "error"}
+	var filter strings.Builder
+	for i, input := range inputs {
+		args = append(args, "-i", input)
+		fmt.Fprintf(&filter, "[%d:a:0]", i)
+	}
+	fmt.Fprintf(&filter, "concat=n=%d:v=0:a=1[out]", len(inputs))
+	args = append(args, "-filter_complex", filter.String(), "-map", "[out]", "-c:a", "libmp3lame", "-b:a", "128k", output)
+	return runFFmpeg(ctx, args...)
+}
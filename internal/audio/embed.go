@@ -0,0 +1,147 @@
+package audio
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Chapter is a titled section of audio.
+type Chapter struct {
+	Start float64 // in seconds
+	End   float64 // in seconds
+	Title string
+}
+
+// EmbedFilename returns the name of the copy of input written by Embed, in
+// folder and with suffix added to the base name.
+func EmbedFilename(input, folder, suffix string) string {
+	ext := filepath.Ext(input)
+	base := strings.TrimSuffix(filepath.Base(input), ext)
+	return filepath.Join(folder, fmt.Sprintf("%s_%s%s", base, suffix, ext))
+}
+
+// Embed writes a copy of the audio file at input to output, with transcript
+// stored in its lyrics tag and chapters added as chapter markers. Existing
+// tags are preserved. The audio itself is copied as is.
+func Embed(ctx context.Context, input, output, transcript string, chapters []Chapter) error {
+	if _, err := exec.LookPath("ffmpeg"); err != nil {
+		return ErrFFmpegNotFound
+	}
+
+	f, err := os.CreateTemp("", "podscript-*.txt")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	metadata := f.Name()
+	f.Close()
+	defer os.Remove(metadata)
+
+	// Start from the existing tags, so that they are not lost
+	if err := runFFmpeg(ctx, "-y", "-v", "error", "-i", input, "-f", "ffmetadata", metadata); err != nil {
+		return err
+	}
+	existing, err := os.ReadFile(metadata)
+	if err != nil {
+		return fmt.Errorf("failed to read metadata file: %w", err)
+	}
+
+	// The transcript is passed in a file rather than as an argument, as it
+	// can be longer than the maximum length of a command line argument
+	merged := mergeMetadata(string(existing), "lyrics="+escapeMetadata(transcript), chapters)
+	if err = os.WriteFile(metadata, []byte(merged), 0644); err != nil {
+		return fmt.Errorf("failed to write metadata file: %w", err)
+	}
+
+	return runFFmpeg(ctx, "-y", "-v", "error",
+		"-i", input,
+		"-f", "ffmetadata", "-i", metadata,
+		"-map", "0", "-map_metadata", "1", "-map_chapters", "1",
+		"-c", "copy",
+		output,
+	)
+}
+
+// mergeMetadata adds the global tag to the FFMETADATA in existing, and the
+// chapters if any. Global tags must come before the first section, such as
+// [STREAM] or [CHAPTER], or they are read as part of it. An existing tag with
+// the same key is replaced, and so are existing chapters if chapters are
+// given.
+func mergeMetadata(existing, tag string, chapters []Chapter) string {
+	key := tag[:strings.Index(tag, "=")+1]
+
+	var (
+		globals  []string
+		sections []string
+		section  []string // lines of the current section
+		skipping bool     // whether the current global tag is dropped
+		cont     bool     // whether the line continues an escaped newline
+	)
+	endSection := func() {
+		if len(section) > 0 && !(len(chapters) > 0 && section[0] == "[CHAPTER]") {
+			sections = append(sections, section...)
+		}
+		section = nil
+	}
+	for _, line := range strings.Split(strings.TrimRight(existing, "\n"), "\n") {
+		if !cont && strings.HasPrefix(line, "[") {
+			endSection()
+			section = []string{line}
+		} else if section != nil {
+			section = append(section, line)
+		} else {
+			if !cont {
+				skipping = strings.HasPrefix(line, key)
+			}
+			if !skipping {
+				globals = append(globals, line)
+			}
+		}
+		cont = escapesNewline(line)
+	}
+	endSection()
+
+	var sb strings.Builder
+	for _, line := range append(append(globals, tag), sections...) {
+		sb.WriteString(line + "\n")
+	}
+	for _, c := range chapters {
+		fmt.Fprintf(&sb, "[CHAPTER]\nTIMEBASE=1/1000\nSTART=%d\nEND=%d\ntitle=%s\n",
+			int64(c.Start*1000), int64(c.End*1000), escapeMetadata(c.Title))
+	}
+	return sb.String()
+}
+
+// escapesNewline reports whether line ends with an unescaped backslash, so
+// that the value continues on the next line.
+func escapesNewline(line string) bool {
+	n := len(line) - len(strings.TrimRight(line, `\`))
+	return n%2 == 1
+}
+
+func runFFmpeg(ctx context.Context, args ...string) error {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("ffmpeg failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// metadataEscaper escapes special characters in values of an FFMETADATA file.
+var metadataEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	"=", `\=`,
+	";", `\;`,
+	"#", `\#`,
+	"\n", "\\\n",
+)
+
+func escapeMetadata(s string) string {
+	return metadataEscaper.Replace(s)
+}
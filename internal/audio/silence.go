@@ -0,0 +1,175 @@
+package audio
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	// Silences at least this long (in seconds) are trimmed.
+	minSilenceDuration = 2.0
+	// Audio quieter than this is treated as silence.
+	silenceNoiseLevel = "-50dB"
+	// Amount of silence (in seconds) kept on either side of a trimmed silence,
+	// so that words before and after it don't run into each other.
+	silencePadding = 0.25
+)
+
+// ErrFFmpegNotFound is returned when ffmpeg is not installed.
+var ErrFFmpegNotFound = errors.New("ffmpeg not found, please install ffmpeg")
+
+var (
+	silenceStartRegex = regexp.MustCompile(`silence_start: (-?[\d.]+)`)
+	silenceEndRegex   = regexp.MustCompile(`silence_end: (-?[\d.]+)`)
+)
+
+// Cut is a section of audio that has been removed.
+type Cut struct {
+	Start    float64 `json:"start"`    // in seconds, relative to the original audio
+	Duration float64 `json:"duration"` // in seconds
+}
+
+// TimingMap records the sections removed from an audio file, so that
+// timestamps in the trimmed audio can be mapped back to the original audio.
+type TimingMap struct {
+	Cuts []Cut `json:"cuts"`
+}
+
+// Original maps a timestamp (in seconds) in the trimmed audio to the
+// corresponding timestamp in the original audio.
+func (m TimingMap) Original(t float64) float64 {
+	for _, c := range m.Cuts {
+		if c.Start > t {
+			break
+		}
+		t += c.Duration
+	}
+	return t
+}
+
+// WriteFile saves the timing map as JSON.
+func (m TimingMap) WriteFile(filename string) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return fmt.Errorf("json.Marshal failed: %w", err)
+	}
+	return os.WriteFile(filename, data, 0644)
+}
+
+// ReadTimingMap reads a timing map saved with WriteFile.
+func ReadTimingMap(filename string) (TimingMap, error) {
+	var m TimingMap
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return m, fmt.Errorf("failed to read timing map: %w", err)
+	}
+	if err = json.Unmarshal(data, &m); err != nil {
+		return m, fmt.Errorf("failed to parse timing map: %w", err)
+	}
+	return m, nil
+}
+
+// TrimSilence removes leading, trailing and long internal silences from the
+// audio file at input. It returns the path to a temporary file with the
+// trimmed audio, which the caller must remove, along with a map of the
+// sections that were removed.
+func TrimSilence(ctx context.Context, input string) (string, TimingMap, error) {
+	if _, err := exec.LookPath("ffmpeg"); err != nil {
+		return "", TimingMap{}, ErrFFmpegNotFound
+	}
+
+	cuts, err := detectSilences(ctx, input)
+	if err != nil {
+		return "", TimingMap{}, err
+	}
+
+	ext := filepath.Ext(input)
+	if ext == "" {
+		ext = ".mp3"
+	}
+	f, err := os.CreateTemp("", "podscript-*"+ext)
+	if err != nil {
+		return "", TimingMap{}, fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	output := f.Name()
+	f.Close()
+
+	args := []string{"-y", "-v", "error", "-i", input}
+	if len(cuts) > 0 {
+		between := make([]string, len(cuts))
+		for i, c := range cuts {
+			between[i] = fmt.Sprintf("between(t,%.3f,%.3f)", c.Start, c.Start+c.Duration)
+		}
+		args = append(args, "-af", fmt.Sprintf("aselect='not(%s)',asetpts=N/SR/TB", strings.Join(between, "+")))
+	}
+	args = append(args, "-vn", output)
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		os.Remove(output)
+		return "", TimingMap{}, fmt.Errorf("ffmpeg failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	return output, TimingMap{Cuts: cuts}, nil
+}
+
+// detectSilences returns the sections of silence to remove from input, using
+// ffmpeg's silencedetect filter.
+func detectSilences(ctx context.Context, input string) ([]Cut, error) {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "ffmpeg",
+		"-i", input,
+		"-af", fmt.Sprintf("silencedetect=noise=%s:d=%g", silenceNoiseLevel, minSilenceDuration),
+		"-f", "null", "-",
+	)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("ffmpeg failed to detect silence: %w", err)
+	}
+
+	var (
+		cuts  []Cut
+		start = -1.0
+	)
+	for _, line := range strings.Split(stderr.String(), "\n") {
+		if m := silenceStartRegex.FindStringSubmatch(line); m != nil {
+			start, _ = strconv.ParseFloat(m[1], 64)
+			start = max(start, 0)
+			continue
+		}
+		m := silenceEndRegex.FindStringSubmatch(line)
+		if m == nil || start < 0 {
+			continue
+		}
+		end, _ := strconv.ParseFloat(m[1], 64)
+		cuts = append(cuts, paddedCut(start, end))
+		start = -1
+	}
+
+	// Silence at the end of the file has no silence_end
+	if start >= 0 {
+		if d, err := Duration(ctx, input); err == nil {
+			cuts = append(cuts, paddedCut(start, d.Seconds()))
+		}
+	}
+	return cuts, nil
+}
+
+func paddedCut(start, end float64) Cut {
+	if start > 0 {
+		start += silencePadding
+	}
+	end -= silencePadding
+	return Cut{Start: start, Duration: max(end-start, 0)}
+}
@@ -0,0 +1,43 @@
+// Package config resolves settings that are shared by all commands.
+package config
+
+import "github.com/spf13/viper"
+
+// Providers of LLM and Speech-To-Text (STT) APIs.
+const (
+	OpenAI     = "openai"
+	Anthropic  = "anthropic"
+	Deepgram   = "deepgram"
+	Groq       = "groq"
+	AssemblyAI = "assemblyai"
+)
+
+// EnvKeys are the config keys that can also be set using environment
+// variables, for e.g. OPENAI_API_KEY.
+var EnvKeys = []string{
+	"openai_api_key",
+	"anthropic_api_key",
+	"deepgram_api_key",
+	"groq_api_key",
+	"assemblyai_api_key",
+	"openai_organization",
+	"openai_project",
+	"ca_cert",
+	"stt_command",
+}
+
+// SetAPIKey overrides the API key for provider for the rest of the run, for
+// e.g. with the value of an --api-key flag.
+func SetAPIKey(provider, key string) {
+	viper.Set(provider+"_api_key", key)
+}
+
+// APIKey returns the API key for provider. Keys are resolved in order of
+// precedence:
+//
+//  1. a key set using SetAPIKey, from the --api-key flag
+//  2. the <PROVIDER>_API_KEY environment variable
+//  3. <provider>_api_key in the config file
+func APIKey(provider string) string {
+	return viper.GetString(provider + "_api_key")
+}
@@ -0,0 +1,59 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestAPIKeyPrecedence(t *testing.T) {
+	tests := []struct {
+		name string
+		flag string
+		env  string
+		file string
+		want string
+	}{
+		{name: "flag over env", flag: "flag-key", env: "env-key", file: "file-key", want: "flag-key"},
+		{name: "env over config", env: "env-key", file: "file-key", want: "env-key"},
+		{name: "config only", file: "file-key", want: "file-key"},
+		{name: "nothing set", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			viper.Reset()
+			t.Cleanup(viper.Reset)
+
+			t.Setenv("DEEPGRAM_API_KEY", tt.env)
+			if tt.env == "" {
+				os.Unsetenv("DEEPGRAM_API_KEY")
+			}
+			for _, k := range EnvKeys {
+				viper.BindEnv(k)
+			}
+
+			configFile := filepath.Join(t.TempDir(), "podscript.toml")
+			var contents string
+			if tt.file != "" {
+				contents = "deepgram_api_key = \"" + tt.file + "\"\n"
+			}
+			if err := os.WriteFile(configFile, []byte(contents), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if err := Read(configFile); err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.flag != "" {
+				SetAPIKey(Deepgram, tt.flag)
+			}
+
+			if got := APIKey(Deepgram); got != tt.want {
+				t.Errorf("APIKey(%q) = %q, want %q", Deepgram, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// A Resolver fetches a TOML config file from a URL, for e.g. from a web server
+// or a secrets manager.
+type Resolver func(u *url.URL) (io.ReadCloser, error)
+
+// resolvers maps URL schemes to the resolvers that handle them.
+var resolvers = map[string]Resolver{
+	"http":  fetchHTTP,
+	"https": fetchHTTP,
+	"vault": fetchVault,
+}
+
+// RegisterResolver makes the config readable from URLs with scheme, for e.g.
+// "vault".
+func RegisterResolver(scheme string, r Resolver) {
+	resolvers[scheme] = r
+}
+
+// IsRemote reports whether location is a URL rather than a local path.
+func IsRemote(location string) bool {
+	return strings.Contains(location, "://")
+}
+
+// Read reads the TOML config from location, which is either a local path or a
+// URL with a scheme that has a registered resolver.
+func Read(location string) error {
+	viper.SetConfigType("toml")
+	if !IsRemote(location) {
+		viper.SetConfigFile(location)
+		return viper.ReadInConfig()
+	}
+
+	u, err := url.Parse(location)
+	if err != nil {
+		return fmt.Errorf("invalid config URL: %w", err)
+	}
+	resolve, ok := resolvers[u.Scheme]
+	if !ok {
+		return fmt.Errorf("unsupported config URL scheme: %s", u.Scheme)
+	}
+	r, err := resolve(u)
+	if err != nil {
+		return fmt.Errorf("failed to fetch config: %w", err)
+	}
+	defer r.Close()
+	if err = viper.ReadConfig(r); err != nil {
+		return fmt.Errorf("failed to parse config: %w", err)
+	}
+	return nil
+}
+
+func fetchHTTP(u *url.URL) (io.ReadCloser, error) {
+	resp, err := http.Get(u.String())
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("request failed with status code %d", resp.StatusCode)
+	}
+	return resp.Body, nil
+}
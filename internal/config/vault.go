@@ -0,0 +1,70 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+// fetchVault reads the config from a secret in HashiCorp Vault, for e.g.
+// vault://secret/data/podscript for the podscript secret of a KV version 2
+// secrets engine mounted at secret. The address of the server and the token
+// are read from the VAULT_ADDR and VAULT_TOKEN environment variables. The
+// secret either has a config field with the TOML config, or a field for each
+// setting, for e.g. openai_api_key.
+func fetchVault(u *url.URL) (io.ReadCloser, error) {
+	addr := os.Getenv("VAULT_ADDR")
+	if addr == "" {
+		return nil, errors.New("VAULT_ADDR environment variable not set")
+	}
+	token := os.Getenv("VAULT_TOKEN")
+	if token == "" {
+		return nil, errors.New("VAULT_TOKEN environment variable not set")
+	}
+
+	secretPath := strings.Trim(u.Host+u.Path, "/")
+	req, err := http.NewRequest(http.MethodGet, strings.TrimRight(addr, "/")+"/v1/"+secretPath, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Vault request: %w", err)
+	}
+	req.Header.Set("X-Vault-Token", token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("request failed with status code %d", resp.StatusCode)
+	}
+
+	var secret struct {
+		Data map[string]any `json:"data"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&secret); err != nil {
+		return nil, fmt.Errorf("json parsing failed: %w", err)
+	}
+	fields := secret.Data
+	// KV version 2 returns the fields of the secret along with its metadata
+	if inner, ok := fields["data"].(map[string]any); ok {
+		if _, ok := fields["metadata"]; ok {
+			fields = inner
+		}
+	}
+
+	if config, ok := fields["config"].(string); ok {
+		return io.NopCloser(strings.NewReader(config)), nil
+	}
+	data, err := toml.Marshal(fields)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert secret to TOML: %w", err)
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
@@ -0,0 +1,180 @@
+// Package download fetches remote audio files, for APIs that can't fetch
+// them directly.
+package download
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/deepakjois/podscript/internal/audio"
+)
+
+// ParseHeaders parses HTTP headers in the form "Name: value".
+func ParseHeaders(headers []string) (http.Header, error) {
+	header := make(http.Header)
+	for _, h := range headers {
+		name, value, ok := strings.Cut(h, ":")
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("invalid header %q, must be in the form \"Name: value\"", h)
+		}
+		header.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+	return header, nil
+}
+
+// ErrFileTooLarge is returned when a file is larger than the limit on its
+// size or duration.
+var ErrFileTooLarge = errors.New("file too large")
+
+// Limits cap the files that are downloaded. Zero values mean no limit.
+type Limits struct {
+	MaxSize     int64 // in bytes
+	MaxDuration time.Duration
+}
+
+var sizeUnits = []struct {
+	suffix string
+	bytes  int64
+}{
+	{"GB", 1024 * 1024 * 1024},
+	{"MB", 1024 * 1024},
+	{"KB", 1024},
+	{"B", 1},
+}
+
+// ParseSize parses a size in bytes, or with a unit of KB, MB or GB, for e.g.
+// "500MB".
+func ParseSize(s string) (int64, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	multiplier := int64(1)
+	for _, unit := range sizeUnits {
+		if n, ok := strings.CutSuffix(s, unit.suffix); ok {
+			s, multiplier = strings.TrimSpace(n), unit.bytes
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q, must be a number of bytes or end with KB, MB or GB", s)
+	}
+	return int64(n * float64(multiplier)), nil
+}
+
+// formatSize formats bytes in MB.
+func formatSize(bytes int64) string {
+	return fmt.Sprintf("%.1f MB", float64(bytes)/(1024*1024))
+}
+
+// File downloads the file at rawURL, sending header with the request, to a
+// temporary file. It returns the path of the file, which the caller must
+// remove. The download is streamed to disk with a progress indicator, and
+// stopped as soon as the file is known to exceed limits.MaxSize. The duration
+// is checked with ffprobe once the file is downloaded.
+func File(ctx context.Context, rawURL string, header http.Header, limits Limits) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid URL: %s", rawURL)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("error creating request: %w", err)
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to download %s: %w", rawURL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to download %s: %s", rawURL, resp.Status)
+	}
+	if limits.MaxSize > 0 && resp.ContentLength > limits.MaxSize {
+		return "", fmt.Errorf("%w: %s is %s, over the limit of %s", ErrFileTooLarge, rawURL, formatSize(resp.ContentLength), formatSize(limits.MaxSize))
+	}
+
+	// Keep the extension, as some tools rely on it to detect the format
+	f, err := os.CreateTemp("", "podscript-*"+path.Ext(u.Path))
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	body := io.Reader(resp.Body)
+	if limits.MaxSize > 0 {
+		// Read one byte more than the limit, to tell if it is exceeded
+		body = io.LimitReader(body, limits.MaxSize+1)
+	}
+	progress := &progressWriter{total: resp.ContentLength}
+	n, err := io.Copy(io.MultiWriter(f, progress), body)
+	progress.done()
+	if err == nil && limits.MaxSize > 0 && n > limits.MaxSize {
+		err = fmt.Errorf("%w: %s is over the limit of %s", ErrFileTooLarge, rawURL, formatSize(limits.MaxSize))
+	} else if err != nil {
+		err = fmt.Errorf("failed to download %s: %w", rawURL, err)
+	}
+	if err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", err
+	}
+	if err = f.Close(); err != nil {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("failed to download %s: %w", rawURL, err)
+	}
+
+	if limits.MaxDuration > 0 {
+		duration, err := audio.Duration(ctx, f.Name())
+		if err != nil {
+			os.Remove(f.Name())
+			return "", fmt.Errorf("failed to check duration: %w", err)
+		}
+		if duration > limits.MaxDuration {
+			os.Remove(f.Name())
+			return "", fmt.Errorf("%w: %s is %s long, over the limit of %s", ErrFileTooLarge, rawURL, duration.Round(time.Second), limits.MaxDuration)
+		}
+	}
+	return f.Name(), nil
+}
+
+// progressWriter prints the progress of a download as it is written.
+type progressWriter struct {
+	total   int64 // or -1 if not known
+	written int64
+	printed time.Time
+}
+
+func (p *progressWriter) Write(b []byte) (int, error) {
+	p.written += int64(len(b))
+	if time.Since(p.printed) >= 500*time.Millisecond {
+		p.print()
+	}
+	return len(b), nil
+}
+
+func (p *progressWriter) print() {
+	if p.total > 0 {
+		fmt.Printf("\rdownloading… %s of %s (%d%%)", formatSize(p.written), formatSize(p.total), p.written*100/p.total)
+	} else {
+		fmt.Printf("\rdownloading… %s", formatSize(p.written))
+	}
+	p.printed = time.Now()
+}
+
+// done prints the final progress, if any progress was printed.
+func (p *progressWriter) done() {
+	if !p.printed.IsZero() {
+		p.print()
+		fmt.Println()
+	}
+}
@@ -0,0 +1,58 @@
+package llm
+
+import (
+	"unicode"
+
+	"github.com/tmc/langchaingo/textsplitter"
+)
+
+func calcWordsFromTokens(tokens int) int {
+	// round down to nearest 1000
+	return int((float64(tokens)*0.75)/1000) * 1000
+}
+
+// CountWords returns the number of words in s, as used to size the chunks of a
+// transcript.
+func CountWords(s string) int {
+	count := 0
+	inWord := false
+
+	for _, char := range s {
+		if unicode.IsSpace(char) {
+			inWord = false
+		} else if !inWord {
+			inWord = true
+			count++
+		}
+	}
+
+	return count
+}
+
+// ChunkSize returns the number of words in each chunk of a transcript, so
+// that the cleaned up chunk fits the output limit of each of the models.
+func ChunkSize(models ...Model) int {
+	var tokens int
+	for _, model := range models {
+		t := MaxTokens(model)
+		if Reasoning(model) {
+			// leave room for reasoning tokens
+			t /= 2
+		}
+		if tokens == 0 || t < tokens {
+			tokens = t
+		}
+	}
+	return calcWordsFromTokens(tokens)
+}
+
+// SplitText splits text into chunks that fit the output limit of each of the
+// models.
+func SplitText(text string, models ...Model) ([]string, error) {
+	splitter := textsplitter.NewRecursiveCharacter(
+		textsplitter.WithChunkSize(ChunkSize(models...)),
+		textsplitter.WithChunkOverlap(0),
+		textsplitter.WithLenFunc(CountWords),
+	)
+	return splitter.SplitText(text)
+}
@@ -0,0 +1,59 @@
+package llm
+
+import (
+	"strings"
+
+	"github.com/deepakjois/podscript/internal/config"
+	"github.com/spf13/viper"
+)
+
+// configuredTokenLimit returns the output token limit for model from the
+// [token-limits] table in the config file, for e.g.
+//
+//	[token-limits]
+//	"gpt-4.1" = 32768
+func configuredTokenLimit(model Model) (int, bool) {
+	// Model names can contain dots, so the table is looked up directly
+	// rather than with a nested viper key
+	for name, v := range viper.GetStringMap("token-limits") {
+		if !strings.EqualFold(name, string(model)) {
+			continue
+		}
+		if n, ok := toFloat(v); ok && n > 0 {
+			return int(n), true
+		}
+	}
+	return 0, false
+}
+
+// TokenLimit returns the maximum number of output tokens for model, from the
+// config file if set there, or the built-in limit otherwise. It reports false
+// if the limit of model is not known.
+func TokenLimit(model Model) (int, bool) {
+	if n, ok := configuredTokenLimit(model); ok {
+		return n, true
+	}
+	n, ok := maxTokens[model]
+	return n, ok
+}
+
+// providerByName guesses the provider of a model that is not built in from
+// its name. Models that are not from OpenAI or Anthropic are assumed to be
+// hosted by Groq.
+func providerByName(model Model) string {
+	name := strings.ToLower(string(model))
+	switch {
+	case strings.HasPrefix(name, "gpt-"), strings.HasPrefix(name, "chatgpt-"), reasoningName(name):
+		return config.OpenAI
+	case strings.HasPrefix(name, "claude-"):
+		return config.Anthropic
+	default:
+		return config.Groq
+	}
+}
+
+// reasoningName reports whether name is that of an OpenAI reasoning model,
+// for e.g. o1 or o3-mini.
+func reasoningName(name string) bool {
+	return len(name) >= 2 && name[0] == 'o' && name[1] >= '1' && name[1] <= '9'
+}
@@ -0,0 +1,188 @@
+// Package llm configures the Large Language Models (LLMs) supported by
+// podscript.
+package llm
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/deepakjois/podscript/internal/config"
+	"github.com/spf13/viper"
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/llms/anthropic"
+	"github.com/tmc/langchaingo/llms/openai"
+)
+
+type Model string
+
+const (
+	ChatGPT4o                 Model = "gpt-4o"
+	ChatGpt4oMini             Model = "gpt-4o-mini"
+	Claude3Dot5Sonnet20240620 Model = "claude-3-5-sonnet-20240620"
+	GroqLlama3170B            Model = "llama-3.1-70b-versatile"
+	O1                        Model = "o1"
+	O1Mini                    Model = "o1-mini"
+	O1Preview                 Model = "o1-preview"
+	O3Mini                    Model = "o3-mini"
+)
+
+// Models lists the supported models.
+var Models = []Model{ChatGpt4oMini, ChatGPT4o, Claude3Dot5Sonnet20240620, GroqLlama3170B, O1, O1Mini, O1Preview, O3Mini}
+
+var (
+	maxTokens map[Model]int = map[Model]int{
+		ChatGPT4o:                 4096,
+		ChatGpt4oMini:             10000,
+		Claude3Dot5Sonnet20240620: 8192,
+		GroqLlama3170B:            8000,
+		O1:                        100000,
+		O1Mini:                    65536,
+		O1Preview:                 32768,
+		O3Mini:                    100000,
+	}
+)
+
+// MaxTokens returns the maximum number of output tokens for model (see
+// TokenLimit).
+func MaxTokens(model Model) int {
+	n, _ := TokenLimit(model)
+	return n
+}
+
+// Supported reports whether model is one of the supported models, or a model
+// with a token limit set in the config file.
+func Supported(model Model) bool {
+	_, ok := TokenLimit(model)
+	return ok
+}
+
+// Reasoning reports whether model is an OpenAI reasoning model. These models
+// only support the default temperature, and their output token limit also
+// covers the tokens used for reasoning.
+func Reasoning(model Model) bool {
+	switch model {
+	case O1, O1Mini, O1Preview, O3Mini:
+		return true
+	default:
+		_, builtIn := maxTokens[model]
+		return !builtIn && reasoningName(strings.ToLower(string(model)))
+	}
+}
+
+// CallOptions returns the options to use when calling model with settings.
+func CallOptions(model Model, settings Settings) []llms.CallOption {
+	if Reasoning(model) {
+		// Reasoning models reject max_tokens (they expect max_completion_tokens
+		// instead) and any temperature other than the default of 1, which
+		// langchaingo would otherwise always send as 0. So settings are
+		// ignored, as reported by IgnoredSettings.
+		return []llms.CallOption{llms.WithTemperature(1)}
+	}
+	maxTokens := MaxTokens(model)
+	if settings.MaxTokens > 0 {
+		maxTokens = settings.MaxTokens
+	}
+	opts := []llms.CallOption{llms.WithMaxTokens(maxTokens)}
+	if settings.Temperature != nil {
+		opts = append(opts, llms.WithTemperature(*settings.Temperature))
+	}
+	if settings.TopP != nil && Provider(model) == config.Anthropic {
+		opts = append(opts, llms.WithTopP(*settings.TopP))
+	}
+	return opts
+}
+
+// IgnoredSettings returns the names of the settings in settings that are not
+// sent to model. The OpenAI client, which is also used for Groq, does not
+// send top_p, nor max_completion_tokens, which reasoning models expect instead
+// of max_tokens.
+func IgnoredSettings(model Model, settings Settings) []string {
+	var ignored []string
+	if Reasoning(model) {
+		if settings.Temperature != nil {
+			ignored = append(ignored, "temperature")
+		}
+		if settings.MaxTokens > 0 {
+			ignored = append(ignored, "max_tokens")
+		}
+	}
+	if settings.TopP != nil && Provider(model) != config.Anthropic {
+		ignored = append(ignored, "top_p")
+	}
+	return ignored
+}
+
+// Provider returns the provider of model, as used by config.APIKey.
+func Provider(model Model) string {
+	switch model {
+	case ChatGPT4o, ChatGpt4oMini, O1, O1Mini, O1Preview, O3Mini:
+		return config.OpenAI
+	case Claude3Dot5Sonnet20240620:
+		return config.Anthropic
+	case GroqLlama3170B:
+		return config.Groq
+	default:
+		if Supported(model) {
+			return providerByName(model)
+		}
+		return ""
+	}
+}
+
+// New returns a client for model, using the API key for its provider.
+func New(model Model) (llms.Model, error) {
+	switch Provider(model) {
+	case config.OpenAI:
+		openaiApiKey := config.APIKey(config.OpenAI)
+		if openaiApiKey == "" {
+			return nil, errors.New("OpenAI API key not found. Please run 'podscript configure' or set the OPENAI_API_KEY environment variable")
+		}
+		opts := []openai.Option{openai.WithToken(openaiApiKey), openai.WithModel(string(model))}
+		if org := viper.GetString("openai_organization"); org != "" {
+			opts = append(opts, openai.WithOrganization(org))
+		}
+		if project := viper.GetString("openai_project"); project != "" {
+			header := make(http.Header)
+			header.Set("OpenAI-Project", project)
+			opts = append(opts, openai.WithHTTPClient(&http.Client{Transport: &headerTransport{header: header}}))
+		}
+		return openai.New(opts...)
+	case config.Anthropic:
+		anthropicApiKey := config.APIKey(config.Anthropic)
+		if anthropicApiKey == "" {
+			return nil, errors.New("Anthropic API key not found. Please run 'podscript configure' or set the ANTHROPIC_API_KEY environment variable")
+		}
+		opts := []anthropic.Option{anthropic.WithToken(anthropicApiKey), anthropic.WithModel(string(model))}
+		if model == Claude3Dot5Sonnet20240620 {
+			opts = append(opts, anthropic.WithAnthropicBetaHeader(anthropic.MaxTokensAnthropicSonnet35))
+		}
+		return anthropic.New(opts...)
+	case config.Groq:
+		groqApiKey := config.APIKey(config.Groq)
+		if groqApiKey == "" {
+			return nil, errors.New("Groq API key not found. Please run 'podscript configure' or set the GROQ_API_KEY environment variable")
+		}
+		return openai.New(
+			openai.WithToken(groqApiKey),
+			openai.WithModel(string(model)),
+			openai.WithBaseURL("https://api.groq.com/openai/v1"),
+		)
+	default:
+		panic(fmt.Sprintf("Invalid model %s. Should not get here!", model))
+	}
+}
+
+// headerTransport adds headers to every request.
+type headerTransport struct {
+	header http.Header
+}
+
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	for k, v := range t.header {
+		req.Header[k] = v
+	}
+	return http.DefaultTransport.RoundTrip(req)
+}
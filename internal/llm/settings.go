@@ -0,0 +1,92 @@
+package llm
+
+import (
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// Settings tune the responses of a model. Zero values use the defaults.
+type Settings struct {
+	Temperature *float64
+	TopP        *float64 // nucleus sampling probability mass
+	MaxTokens   int
+
+	// TokensPerMinute paces requests to stay under a rate limit, if positive.
+	// If nil, the setting in the config file is used.
+	TokensPerMinute *int
+}
+
+// ModelSettings returns the settings for model from its [models.<name>] table
+// in the config file, for e.g.
+//
+//	[models."gpt-4o"]
+//	temperature = 0.2
+//	top_p = 0.9
+//	max_tokens = 4096
+//	tokens_per_minute = 6000
+//
+// Fields set in overrides, for e.g. from command line flags, take precedence.
+func ModelSettings(model Model, overrides Settings) Settings {
+	var s Settings
+	// Model names can contain dots, so the table is looked up directly
+	// rather than with a nested viper key
+	for name, v := range viper.GetStringMap("models") {
+		table, ok := v.(map[string]any)
+		if !ok || !strings.EqualFold(name, string(model)) {
+			continue
+		}
+		if t, ok := toFloat(table["temperature"]); ok {
+			s.Temperature = &t
+		}
+		if p, ok := toFloat(table["top_p"]); ok {
+			s.TopP = &p
+		}
+		if n, ok := toFloat(table["max_tokens"]); ok {
+			s.MaxTokens = int(n)
+		}
+		if n, ok := toFloat(table["tokens_per_minute"]); ok {
+			tpm := int(n)
+			s.TokensPerMinute = &tpm
+		}
+	}
+
+	if overrides.Temperature != nil {
+		s.Temperature = overrides.Temperature
+	}
+	if overrides.TopP != nil {
+		s.TopP = overrides.TopP
+	}
+	if overrides.MaxTokens > 0 {
+		s.MaxTokens = overrides.MaxTokens
+	}
+	if overrides.TokensPerMinute != nil {
+		s.TokensPerMinute = overrides.TokensPerMinute
+	}
+	return s
+}
+
+// TokensPerMinute returns the rate at which requests are paced with settings,
+// or 0 if they are not paced. Requests are only paced if configured, as a
+// limit below the size of a part of the transcript, for e.g. the 6000 tokens
+// per minute of the Groq free tier, would hold up every request.
+func TokensPerMinute(settings Settings) int {
+	if settings.TokensPerMinute != nil {
+		return max(*settings.TokensPerMinute, 0)
+	}
+	return 0
+}
+
+// toFloat converts a number decoded from TOML to a float64.
+func toFloat(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int64:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	default:
+		return 0, false
+	}
+}
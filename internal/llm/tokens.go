@@ -0,0 +1,31 @@
+package llm
+
+import (
+	"fmt"
+
+	"github.com/deepakjois/podscript/internal/config"
+	"github.com/pkoukk/tiktoken-go"
+)
+
+// Tokenizers used to count tokens.
+const (
+	encodingO200K  = "o200k_base"  // used by gpt-4o and the reasoning models
+	encodingCL100K = "cl100k_base" // approximates models without a public tokenizer
+)
+
+// CountTokens returns the number of tokens in text for model, along with the
+// name of the tokenizer used and whether the count is exact. OpenAI models
+// are counted with their own tokenizer. Anthropic and Groq models don't have
+// a public tokenizer, so their count is an approximation. The tokenizer is
+// downloaded the first time it is used.
+func CountTokens(model Model, text string) (int, string, bool, error) {
+	encoding, exact := encodingCL100K, false
+	if Provider(model) == config.OpenAI {
+		encoding, exact = encodingO200K, true
+	}
+	e, err := tiktoken.GetEncoding(encoding)
+	if err != nil {
+		return 0, encoding, false, fmt.Errorf("failed to load tokenizer %s: %w", encoding, err)
+	}
+	return len(e.Encode(text, nil, nil)), encoding, exact, nil
+}
@@ -0,0 +1,175 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"net"
+	"regexp"
+	"strings"
+
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/llms/openai"
+)
+
+// Usage is the number of tokens consumed by one or more completions.
+type Usage struct {
+	InputTokens  int
+	OutputTokens int
+}
+
+// Add returns the sum of u and other.
+func (u Usage) Add(other Usage) Usage {
+	return Usage{
+		InputTokens:  u.InputTokens + other.InputTokens,
+		OutputTokens: u.OutputTokens + other.OutputTokens,
+	}
+}
+
+// ModelUsage is the usage of each of the models that served completions, for
+// e.g. when fallback models cleaned up some parts of a transcript.
+type ModelUsage map[Model]Usage
+
+// Add returns u with usage by model added. u may be nil.
+func (u ModelUsage) Add(model Model, usage Usage) ModelUsage {
+	if usage == (Usage{}) {
+		return u
+	}
+	if u == nil {
+		u = make(ModelUsage)
+	}
+	u[model] = u[model].Add(usage)
+	return u
+}
+
+// Merge returns u with the usage in other added. u may be nil.
+func (u ModelUsage) Merge(other ModelUsage) ModelUsage {
+	for model, usage := range other {
+		u = u.Add(model, usage)
+	}
+	return u
+}
+
+// Total returns the usage of all the models.
+func (u ModelUsage) Total() Usage {
+	var total Usage
+	for _, usage := range u {
+		total = total.Add(usage)
+	}
+	return total
+}
+
+// Cost returns the estimated cost in USD of the usage, priced for each model.
+func (u ModelUsage) Cost() float64 {
+	var cost float64
+	for model, usage := range u {
+		cost += Cost(model, usage)
+	}
+	return cost
+}
+
+// price is the cost in USD per million tokens.
+type price struct {
+	input  float64
+	output float64
+}
+
+// Prices as of Jul 2024, and Feb 2025 for the reasoning models.
+var prices = map[Model]price{
+	ChatGPT4o:                 {input: 2.50, output: 10.00},
+	ChatGpt4oMini:             {input: 0.15, output: 0.60},
+	Claude3Dot5Sonnet20240620: {input: 3.00, output: 15.00},
+	GroqLlama3170B:            {input: 0.59, output: 0.79},
+	O1:                        {input: 15.00, output: 60.00},
+	O1Mini:                    {input: 1.10, output: 4.40},
+	O1Preview:                 {input: 15.00, output: 60.00},
+	O3Mini:                    {input: 1.10, output: 4.40},
+}
+
+// Cost returns the estimated cost in USD of usage for model.
+func Cost(model Model, usage Usage) float64 {
+	p := prices[model]
+	return (float64(usage.InputTokens)*p.input + float64(usage.OutputTokens)*p.output) / 1_000_000
+}
+
+// EstimateUsage approximates the tokens needed to clean up a transcript with
+// the given number of words, assuming the response is about as long as the
+// transcript.
+func EstimateUsage(words int) Usage {
+	tokens := words * 4 / 3
+	return Usage{InputTokens: tokens, OutputTokens: tokens}
+}
+
+// ErrNoChoices is returned by Generate when the provider responds without any
+// choices, for e.g. when the response is blocked by content filtering.
+var ErrNoChoices = errors.New("no choices returned")
+
+// Generate calls model with a single prompt, and returns the text of the
+// response along with the tokens consumed.
+func Generate(ctx context.Context, model llms.Model, prompt string, options ...llms.CallOption) (string, Usage, error) {
+	msg := llms.MessageContent{
+		Role:  llms.ChatMessageTypeHuman,
+		Parts: []llms.ContentPart{llms.TextContent{Text: prompt}},
+	}
+
+	resp, err := model.GenerateContent(ctx, []llms.MessageContent{msg}, options...)
+	if emptyResponse(err) {
+		return "", Usage{}, ErrNoChoices
+	}
+	if err != nil {
+		return "", Usage{}, err
+	}
+	if len(resp.Choices) < 1 {
+		return "", Usage{}, ErrNoChoices
+	}
+
+	choice := resp.Choices[0]
+	return choice.Content, usageFromGenerationInfo(choice.GenerationInfo), nil
+}
+
+// emptyResponse reports whether err is returned by the OpenAI client, which is
+// also used for Groq, when the response has no choices. It checks for them
+// itself, and returns "no response" or, from its internal client, "empty
+// response", which is not exported.
+func emptyResponse(err error) bool {
+	return errors.Is(err, openai.ErrEmptyResponse) || (err != nil && err.Error() == "empty response")
+}
+
+// statusCodeRegex matches the HTTP status code in errors returned by the
+// OpenAI and Anthropic clients, which are not typed.
+var statusCodeRegex = regexp.MustCompile(`status code: (\d{3})\b`)
+
+// Retryable reports whether err is due to the provider being overloaded or
+// rate limited, or to a network error, so that the request may succeed later
+// or with another model.
+func Retryable(err error) bool {
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	if m := statusCodeRegex.FindStringSubmatch(err.Error()); m != nil {
+		switch m[1] {
+		case "429", "500", "502", "503", "504", "529":
+			return true
+		}
+		return false
+	}
+	// Anthropic reports overloading in the error type as well as the status
+	return strings.Contains(strings.ToLower(err.Error()), "overloaded")
+}
+
+// usageFromGenerationInfo extracts token counts, which are reported under
+// different keys by each provider.
+func usageFromGenerationInfo(info map[string]any) Usage {
+	tokens := func(keys ...string) int {
+		for _, k := range keys {
+			if v, ok := info[k].(int); ok {
+				return v
+			}
+		}
+		return 0
+	}
+	return Usage{
+		InputTokens:  tokens("PromptTokens", "InputTokens"),
+		OutputTokens: tokens("CompletionTokens", "OutputTokens"),
+	}
+}
@@ -0,0 +1,67 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/llms/openai"
+)
+
+// fakeModel responds to every call with resp and err.
+type fakeModel struct {
+	resp *llms.ContentResponse
+	err  error
+}
+
+func (m fakeModel) GenerateContent(context.Context, []llms.MessageContent, ...llms.CallOption) (*llms.ContentResponse, error) {
+	return m.resp, m.err
+}
+
+func (m fakeModel) Call(context.Context, string, ...llms.CallOption) (string, error) {
+	return "", m.err
+}
+
+func TestGenerateNoChoices(t *testing.T) {
+	tests := []struct {
+		name  string
+		model llms.Model
+	}{
+		{name: "no choices", model: fakeModel{resp: &llms.ContentResponse{}}},
+		{name: "openai no response", model: fakeModel{err: openai.ErrEmptyResponse}},
+		{name: "openai client empty response", model: fakeModel{err: errors.New("empty response")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := Generate(context.Background(), tt.model, "prompt"); !errors.Is(err, ErrNoChoices) {
+				t.Errorf("Generate() error = %v, want %v", err, ErrNoChoices)
+			}
+		})
+	}
+}
+
+func TestGenerateOpenAIEmptyChoices(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices": []}`))
+	}))
+	defer srv.Close()
+
+	model, err := openai.New(openai.WithToken("test"), openai.WithModel(string(ChatGpt4oMini)), openai.WithBaseURL(srv.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = Generate(context.Background(), model, "prompt"); !errors.Is(err, ErrNoChoices) {
+		t.Errorf("Generate() error = %v, want %v", err, ErrNoChoices)
+	}
+}
+
+func TestGenerateError(t *testing.T) {
+	want := errors.New("API returned unexpected status code: 401: invalid key")
+	if _, _, err := Generate(context.Background(), fakeModel{err: want}, "prompt"); err != want {
+		t.Errorf("Generate() error = %v, want %v", err, want)
+	}
+}
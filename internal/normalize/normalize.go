@@ -0,0 +1,376 @@
+// Package normalize rewrites numbers, dates and amounts of money in a
+// transcript in a consistent style, using fixed rules rather than an LLM.
+package normalize
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Kinds of normalization.
+const (
+	Numbers  = "numbers"
+	Dates    = "dates"
+	Currency = "currency"
+)
+
+// Kinds lists the supported kinds of normalization, in the order they are
+// applied.
+var Kinds = []string{Numbers, Dates, Currency}
+
+// Valid reports whether kind is a supported kind of normalization.
+func Valid(kind string) bool {
+	for _, k := range Kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// Text applies each of kinds of normalization to text. Numbers are normalized
+// first, so that spelled out amounts and dates are picked up by the later
+// rules.
+func Text(text string, kinds []string) string {
+	enabled := make(map[string]bool, len(kinds))
+	for _, k := range kinds {
+		enabled[k] = true
+	}
+	if enabled[Numbers] {
+		text = numbers(text)
+	}
+	if enabled[Dates] {
+		text = dates(text)
+	}
+	if enabled[Currency] {
+		text = currency(text)
+	}
+	return text
+}
+
+// Numbers
+
+type wordKind int
+
+const (
+	kindNone wordKind = iota
+	kindUnit
+	kindTeen
+	kindTens
+	kindHundred
+	kindScale
+)
+
+type numberWord struct {
+	value   int64
+	kind    wordKind
+	ordinal bool
+}
+
+var numberWords = map[string]numberWord{}
+
+func init() {
+	cardinals := []struct {
+		words []string
+		kind  wordKind
+		start int64
+		step  int64
+	}{
+		{[]string{"zero", "one", "two", "three", "four", "five", "six", "seven", "eight", "nine"}, kindUnit, 0, 1},
+		{[]string{"ten", "eleven", "twelve", "thirteen", "fourteen", "fifteen", "sixteen", "seventeen", "eighteen", "nineteen"}, kindTeen, 10, 1},
+		{[]string{"twenty", "thirty", "forty", "fifty", "sixty", "seventy", "eighty", "ninety"}, kindTens, 20, 10},
+	}
+	ordinals := map[string]string{
+		"one": "first", "two": "second", "three": "third", "five": "fifth",
+		"eight": "eighth", "nine": "ninth", "twelve": "twelfth",
+	}
+	for _, c := range cardinals {
+		for i, w := range c.words {
+			value := c.start + int64(i)*c.step
+			numberWords[w] = numberWord{value: value, kind: c.kind}
+			ordinal, ok := ordinals[w]
+			switch {
+			case ok:
+			case c.kind == kindTens:
+				ordinal = strings.TrimSuffix(w, "y") + "ieth"
+			default:
+				ordinal = w + "th"
+			}
+			if w != "zero" {
+				numberWords[ordinal] = numberWord{value: value, kind: c.kind, ordinal: true}
+			}
+		}
+	}
+	numberWords["hundred"] = numberWord{value: 100, kind: kindHundred}
+	numberWords["hundredth"] = numberWord{value: 100, kind: kindHundred, ordinal: true}
+	for w, v := range map[string]int64{"thousand": 1e3, "million": 1e6, "billion": 1e9} {
+		numberWords[w] = numberWord{value: v, kind: kindScale}
+		numberWords[w+"th"] = numberWord{value: v, kind: kindScale, ordinal: true}
+	}
+
+	words := make([]string, 0, len(numberWords))
+	for w := range numberWords {
+		words = append(words, w)
+	}
+	// Longer words first, so that for e.g. "sixteen" is not matched as "six"
+	sort.Slice(words, func(i, j int) bool { return len(words[i]) > len(words[j]) })
+	word := `(?:` + strings.Join(words, "|") + `)`
+	numberRunRegex = regexp.MustCompile(`(?i)\b` + word + `(?:(?:[ -]+|\s+and\s+)` + word + `)*\b`)
+}
+
+var (
+	numberRunRegex *regexp.Regexp
+	andRegex       = regexp.MustCompile(`(?i)\s+and\s+`)
+	separatorRegex = regexp.MustCompile(`[ -]+`)
+	percentRegex   = regexp.MustCompile(`(?i)\b(\d+(?:\.\d+)?) ?(?:percent|per cent)\b`)
+)
+
+// numbers rewrites spelled out numbers as digits, for e.g. "twenty four" as
+// "24", "twenty twenty four" as "2024" and "twenty first" as "21st". Single
+// word numbers below ten are left as words. Spans of words that are not a
+// single valid number are left unchanged.
+func numbers(text string) string {
+	text = numberRunRegex.ReplaceAllStringFunc(text, convertRun)
+	return percentRegex.ReplaceAllString(text, "$1%")
+}
+
+func convertRun(run string) string {
+	if s, ok := convertTokens(separatorRegex.Split(andRegex.ReplaceAllString(run, " and "), -1)); ok {
+		return s
+	}
+
+	// "and" may separate two numbers, rather than being part of one
+	parts := andRegex.Split(run, -1)
+	if len(parts) == 1 {
+		return run
+	}
+	seps := andRegex.FindAllString(run, -1)
+	var sb strings.Builder
+	for i, part := range parts {
+		if s, ok := convertTokens(separatorRegex.Split(part, -1)); ok {
+			sb.WriteString(s)
+		} else {
+			sb.WriteString(part)
+		}
+		if i < len(seps) {
+			sb.WriteString(seps[i])
+		}
+	}
+	return sb.String()
+}
+
+// convertTokens converts the words of a number to digits, and reports whether
+// they are a valid number.
+func convertTokens(tokens []string) (string, bool) {
+	value, ordinal, scale, ok := parseNumber(tokens)
+	if ok {
+		if len(tokens) == 1 && value < 10 {
+			return tokens[0], true
+		}
+		if scale != "" {
+			return formatNumber(value) + " " + scale, true
+		}
+		if ordinal {
+			return formatNumber(value) + ordinalSuffix(value), true
+		}
+		return formatNumber(value), true
+	}
+
+	// Years are usually spoken as two pairs of digits. Only years from 1400
+	// are converted, as pairs like "ten ten", "eleven thirty" or "sixty forty"
+	// are more often scores, times or ratios.
+	for k := 1; k < len(tokens); k++ {
+		first, firstOrdinal, firstScale, ok1 := parseNumber(tokens[:k])
+		second, ordinal, secondScale, ok2 := parseNumber(tokens[k:])
+		if ok1 && ok2 && !firstOrdinal && firstScale == "" && secondScale == "" &&
+			first >= 14 && first <= 20 && second >= 10 && second <= 99 {
+			year := first*100 + second
+			if ordinal {
+				return strconv.FormatInt(year, 10) + ordinalSuffix(year), true
+			}
+			return strconv.FormatInt(year, 10), true
+		}
+	}
+	return "", false
+}
+
+// parseNumber parses the words of a number. If the number is a multiple of a
+// million or billion, value is the multiplier and scale is the word for it, as
+// in "20 million".
+func parseNumber(tokens []string) (value int64, ordinal bool, scale string, ok bool) {
+	var (
+		total, current int64
+		last           = kindNone
+		lastScale      int64
+	)
+	for i, token := range tokens {
+		token = strings.ToLower(token)
+		if token == "and" {
+			if (last != kindHundred && last != kindScale) || i == len(tokens)-1 {
+				return 0, false, "", false
+			}
+			continue
+		}
+		w, found := numberWords[token]
+		if !found || (w.ordinal && i != len(tokens)-1) {
+			return 0, false, "", false
+		}
+		ordinal = w.ordinal
+
+		switch w.kind {
+		case kindUnit:
+			if last == kindUnit || last == kindTeen || (w.value == 0 && len(tokens) > 1) {
+				return 0, false, "", false
+			}
+			current += w.value
+		case kindTeen, kindTens:
+			if last != kindNone && last != kindHundred && last != kindScale {
+				return 0, false, "", false
+			}
+			current += w.value
+		case kindHundred:
+			if (last != kindUnit && last != kindTeen) || current >= 100 {
+				return 0, false, "", false
+			}
+			current *= 100
+		case kindScale:
+			if current == 0 || (lastScale != 0 && w.value >= lastScale) {
+				return 0, false, "", false
+			}
+			total += current * w.value
+			current = 0
+			lastScale = w.value
+		}
+		last = w.kind
+	}
+
+	if last == kindScale && lastScale >= 1e6 && !ordinal && total%lastScale == 0 {
+		return total / lastScale, false, strings.ToLower(tokens[len(tokens)-1]), true
+	}
+	return total + current, ordinal, "", true
+}
+
+// formatNumber formats n in digits, with thousands separators from 10,000.
+func formatNumber(n int64) string {
+	s := strconv.FormatInt(n, 10)
+	if n < 10000 {
+		return s
+	}
+	var sb strings.Builder
+	for i, c := range s {
+		if i > 0 && (len(s)-i)%3 == 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteRune(c)
+	}
+	return sb.String()
+}
+
+func ordinalSuffix(n int64) string {
+	switch {
+	case n%100 >= 11 && n%100 <= 13:
+		return "th"
+	case n%10 == 1:
+		return "st"
+	case n%10 == 2:
+		return "nd"
+	case n%10 == 3:
+		return "rd"
+	default:
+		return "th"
+	}
+}
+
+// Dates
+
+const (
+	month = `(January|February|March|April|May|June|July|August|September|October|November|December)`
+	day   = `(\d{1,2}(?:st|nd|rd|th)?|(?i:first|second|third|fourth|fifth|sixth|seventh|eighth|ninth))`
+)
+
+var (
+	dayOfMonthRegex = regexp.MustCompile(`\b(?i:the\s+)?` + day + `(?i:\s+of)\s+` + month + `\b`)
+	monthDayRegex   = regexp.MustCompile(`\b` + month + `(\s+(?i:the))?\s+` + day + `\b`)
+	dateYearRegex   = regexp.MustCompile(`\b` + month + ` (\d{1,2}),? (\d{4})\b`)
+	daySuffixRegex  = regexp.MustCompile(`^(\d{1,2})(?:st|nd|rd|th)?$`)
+)
+
+// dates rewrites dates as, for e.g. "January 5, 2024", from forms like "the
+// fifth of January 2024" or "January 5th 2024".
+func dates(text string) string {
+	text = dayOfMonthRegex.ReplaceAllStringFunc(text, func(s string) string {
+		m := dayOfMonthRegex.FindStringSubmatch(s)
+		if d, ok := dayNumber(m[1]); ok {
+			return m[2] + " " + d
+		}
+		return s
+	})
+	text = monthDayRegex.ReplaceAllStringFunc(text, func(s string) string {
+		m := monthDayRegex.FindStringSubmatch(s)
+		// "May the" is more often the verb, as in "May the fourth be with you"
+		if m[1] == "May" && m[2] != "" {
+			return s
+		}
+		if d, ok := dayNumber(m[3]); ok {
+			return m[1] + " " + d
+		}
+		return s
+	})
+	return dateYearRegex.ReplaceAllString(text, "$1 $2, $3")
+}
+
+// dayNumber returns the day of the month in digits, and reports whether it is
+// a valid day.
+func dayNumber(s string) (string, bool) {
+	if m := daySuffixRegex.FindStringSubmatch(s); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		return m[1], n >= 1 && n <= 31
+	}
+	w, ok := numberWords[strings.ToLower(s)]
+	if !ok || !w.ordinal {
+		return "", false
+	}
+	return strconv.FormatInt(w.value, 10), true
+}
+
+// Currency
+
+// amount matches an amount in digits, or a number below ten in words, which
+// numbers leaves as a word.
+const amount = `(\d[\d,]*(?:\.\d+)?|one|two|three|four|five|six|seven|eight|nine)(\s+(?:million|billion|trillion))?`
+
+var (
+	dollarRegex = regexp.MustCompile(`(?i)\$?\b` + amount + `\s+(?:US\s+)?dollars?\b`)
+	euroRegex   = regexp.MustCompile(`(?i)€?\b` + amount + `\s+euros?\b`)
+	centsRegex  = regexp.MustCompile(`(?i)\$(\d[\d,]*)\s+and\s+(\d{1,2}|one|two|three|four|five|six|seven|eight|nine)\s+cents?\b`)
+)
+
+// currency rewrites amounts of money with a currency symbol, for e.g. "five
+// dollars and 50 cents" as "$5.50" and "20 million euros" as "€20 million".
+func currency(text string) string {
+	text = replaceAmount(text, dollarRegex, "$")
+	text = replaceAmount(text, euroRegex, "€")
+	return centsRegex.ReplaceAllStringFunc(text, func(s string) string {
+		m := centsRegex.FindStringSubmatch(s)
+		cents, _ := strconv.Atoi(digits(m[2]))
+		return fmt.Sprintf("$%s.%02d", m[1], cents)
+	})
+}
+
+func replaceAmount(text string, re *regexp.Regexp, symbol string) string {
+	return re.ReplaceAllStringFunc(text, func(s string) string {
+		m := re.FindStringSubmatch(s)
+		return symbol + digits(m[1]) + strings.ToLower(m[2])
+	})
+}
+
+// digits returns s in digits, if it is a number word.
+func digits(s string) string {
+	if w, ok := numberWords[strings.ToLower(s)]; ok && !w.ordinal {
+		return strconv.FormatInt(w.value, 10)
+	}
+	return s
+}
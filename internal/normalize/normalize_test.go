@@ -0,0 +1,75 @@
+package normalize
+
+import "testing"
+
+func TestText(t *testing.T) {
+	tests := []struct {
+		name string
+		kind string
+		in   string
+		want string
+	}{
+		// Numbers
+		{name: "compound", kind: Numbers, in: "twenty four hours", want: "24 hours"},
+		{name: "hyphenated", kind: Numbers, in: "forty-two", want: "42"},
+		{name: "hundreds with and", kind: Numbers, in: "one hundred and one dalmatians", want: "101 dalmatians"},
+		{name: "thousands", kind: Numbers, in: "one thousand and one nights", want: "1001 nights"},
+		{name: "separators", kind: Numbers, in: "twelve thousand five hundred", want: "12,500"},
+		{name: "millions", kind: Numbers, in: "twenty million people", want: "20 million people"},
+		{name: "ordinal", kind: Numbers, in: "the twenty first century", want: "the 21st century"},
+		{name: "year", kind: Numbers, in: "in nineteen ninety nine", want: "in 1999"},
+		{name: "recent year", kind: Numbers, in: "twenty twenty four", want: "2024"},
+		{name: "percent", kind: Numbers, in: "twenty five percent", want: "25%"},
+		{name: "small numbers kept", kind: Numbers, in: "one or two things", want: "one or two things"},
+		{name: "small ordinals kept", kind: Numbers, in: "first and second", want: "first and second"},
+		{name: "one of the", kind: Numbers, in: "it was one of the best", want: "it was one of the best"},
+		{name: "one and only", kind: Numbers, in: "the one and only", want: "the one and only"},
+		{name: "a hundred", kind: Numbers, in: "a hundred times", want: "a hundred times"},
+		{name: "and between numbers", kind: Numbers, in: "forty two and a half", want: "42 and a half"},
+		{name: "score", kind: Numbers, in: "it ended ten ten", want: "it ended ten ten"},
+		{name: "time", kind: Numbers, in: "at eleven thirty", want: "at eleven thirty"},
+		{name: "ratio", kind: Numbers, in: "a sixty forty split", want: "a sixty forty split"},
+		{name: "invalid", kind: Numbers, in: "one one", want: "one one"},
+
+		// Dates
+		{name: "day of month", kind: Dates, in: "on the fifth of January", want: "on January 5"},
+		{name: "day of month with year", kind: Dates, in: "the fifth of January 2024", want: "January 5, 2024"},
+		{name: "month day suffix", kind: Dates, in: "March 3rd 2023", want: "March 3, 2023"},
+		{name: "month the day", kind: Dates, in: "January the first", want: "January 1"},
+		{name: "comma kept", kind: Dates, in: "May 4th, 2024", want: "May 4, 2024"},
+		{name: "may the", kind: Dates, in: "May the fourth be with you", want: "May the fourth be with you"},
+		{name: "invalid day", kind: Dates, in: "the 40th of June", want: "the 40th of June"},
+		{name: "second of all", kind: Dates, in: "second of all", want: "second of all"},
+
+		// Currency
+		{name: "dollars", kind: Currency, in: "it costs 20 dollars", want: "it costs $20"},
+		{name: "dollar word", kind: Currency, in: "one dollar", want: "$1"},
+		{name: "US dollars", kind: Currency, in: "5 US dollars", want: "$5"},
+		{name: "cents", kind: Currency, in: "five dollars and 50 cents", want: "$5.50"},
+		{name: "euros", kind: Currency, in: "20 million euros", want: "€20 million"},
+		{name: "a million", kind: Currency, in: "a million dollars", want: "a million dollars"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.kind+"/"+tt.name, func(t *testing.T) {
+			if got := Text(tt.in, []string{tt.kind}); got != tt.want {
+				t.Errorf("Text(%q, %s) = %q, want %q", tt.in, tt.kind, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTextAllKinds(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{in: "twenty dollars and fifty cents", want: "$20.50"},
+		{in: "the twenty first of March twenty twenty four", want: "March 21, 2024"},
+		{in: "twenty five percent of one hundred euros", want: "25% of €100"},
+	}
+	for _, tt := range tests {
+		if got := Text(tt.in, Kinds); got != tt.want {
+			t.Errorf("Text(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+// Package output provides helpers shared by commands to write transcripts and
+// API responses.
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Encodings of transcript files.
+const (
+	EncodingUTF8    = "utf-8"
+	EncodingUTF8BOM = "utf-8-bom" // with a byte order mark, for Windows tools
+)
+
+var bom = []byte{0xEF, 0xBB, 0xBF}
+
+// noBOM lists the extensions of files that are never written with a byte
+// order mark, as JSON parsers and line-oriented JSON readers reject it.
+var noBOM = map[string]bool{".json": true, ".jsonl": true}
+
+// encoding is the encoding used by Create and WriteFile.
+var encoding = EncodingUTF8
+
+// SetEncoding sets the encoding of transcript files written by Create and
+// WriteFile.
+func SetEncoding(enc string) error {
+	switch enc {
+	case EncodingUTF8, EncodingUTF8BOM:
+		encoding = enc
+		return nil
+	default:
+		return fmt.Errorf("invalid output encoding: must be one of %s, %s", EncodingUTF8, EncodingUTF8BOM)
+	}
+}
+
+// footer is appended to transcripts by File.Close.
+var footer string
+
+// SetFooter sets the text appended to transcript files, for e.g. an
+// attribution or license.
+func SetFooter(text string) {
+	footer = strings.TrimSpace(text)
+}
+
+// Footer returns the text appended to transcript files.
+func Footer() string {
+	return footer
+}
+
+// proseFooter lists the extensions of prose files that the footer is appended
+// to as text. Other formats, for e.g. CSV, subtitles or JSON and YAML written
+// with a template, would be corrupted by it.
+var proseFooter = map[string]bool{".txt": true, ".md": true, ".markdown": true}
+
+// File is a transcript file.
+type File struct {
+	*os.File
+	wrapper *wrapper // wraps lines of plain text, if set
+}
+
+// Create creates a transcript file. A byte order mark is written first if
+// required by the encoding, except to JSON files. Plain text written to the
+// file is wrapped if a width is set with SetWrap.
+func Create(filename string) (*File, error) {
+	f, err := os.Create(filename)
+	if err != nil {
+		return nil, err
+	}
+	if encoding == EncodingUTF8BOM && !noBOM[filepath.Ext(filename)] {
+		if _, err = f.Write(bom); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	file := &File{File: f}
+	if wraps(filename) {
+		file.wrapper = &wrapper{width: wrapWidth}
+	}
+	return file, nil
+}
+
+// Write writes p to the file, wrapping lines if required.
+func (f *File) Write(p []byte) (int, error) {
+	if f.wrapper == nil {
+		return f.File.Write(p)
+	}
+	if _, err := f.File.Write(f.wrapper.wrap(p)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Close appends the footer, if any, and closes the file. In JSON Lines files
+// the footer is written as an object with a footer field, rather than as text.
+// HTML files include the footer in the page, so it is not appended. Nor is it
+// appended to other formats that are not prose, where it would be read as a
+// row, a cue or invalid data.
+func (f *File) Close() error {
+	ext := filepath.Ext(f.Name())
+	if footer != "" && (proseFooter[ext] || ext == ".jsonl") {
+		var err error
+		if ext == ".jsonl" {
+			err = json.NewEncoder(f.File).Encode(struct {
+				Footer string `json:"footer"`
+			}{footer})
+		} else {
+			_, err = fmt.Fprintf(f.File, "\n\n%s\n", footer)
+		}
+		if err != nil {
+			f.File.Close()
+			return err
+		}
+	}
+	return f.File.Close()
+}
+
+// WriteFile writes data to a transcript file in the configured encoding.
+func WriteFile(filename string, data []byte) error {
+	f, err := Create(filename)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// unsafeNameRegex matches runs of characters that are not safe in a part of
+// a filename, for e.g. the / in a model name such as "meta-llama/llama-3".
+var unsafeNameRegex = regexp.MustCompile(`[^\w.-]+`)
+
+// SafeName returns name with characters that are not safe in filenames
+// replaced by "-", so that it can be used as part of a filename.
+func SafeName(name string) string {
+	return unsafeNameRegex.ReplaceAllString(name, "-")
+}
+
+// CheckDir verifies that dir exists and is writable, so that commands can
+// fail before making any expensive API calls. An empty dir refers to the
+// current directory.
+func CheckDir(dir string) error {
+	if dir == "" {
+		dir = "."
+	}
+
+	fi, err := os.Stat(dir)
+	if err != nil || !fi.IsDir() {
+		return fmt.Errorf("path not found: %s", dir)
+	}
+
+	f, err := os.CreateTemp(dir, ".podscript-*")
+	if err != nil {
+		return fmt.Errorf("path not writable: %s", dir)
+	}
+	f.Close()
+	os.Remove(f.Name())
+	return nil
+}
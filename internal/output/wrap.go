@@ -0,0 +1,78 @@
+package output
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// wrapWidth is the column at which plain text transcripts are wrapped, or 0
+// if they are not wrapped.
+var wrapWidth int
+
+// SetWrap sets the column at which lines of plain text transcript files
+// written by Create and WriteFile are wrapped. A width of 0 disables wrapping.
+func SetWrap(width int) error {
+	if width < 0 {
+		return fmt.Errorf("invalid wrap width: %d", width)
+	}
+	wrapWidth = width
+	return nil
+}
+
+// wrapper hard-wraps text at word boundaries, keeping existing line breaks.
+// It keeps track of the column across writes, so that a transcript can be
+// written in parts. Runs of spaces between words are collapsed.
+type wrapper struct {
+	width int
+	col   int  // runes written on the current line
+	space bool // a space is pending before the next word
+}
+
+// wraps reports whether files named filename are wrapped. Only plain text is
+// wrapped, as breaking lines would change the data in other formats.
+func wraps(filename string) bool {
+	return wrapWidth > 0 && filepath.Ext(filename) == ".txt"
+}
+
+// wrap returns p wrapped, continuing from the column reached by the previous
+// call. Each call is assumed to end at a word boundary.
+func (w *wrapper) wrap(p []byte) []byte {
+	var sb strings.Builder
+	for len(p) > 0 {
+		r, size := utf8.DecodeRune(p)
+		switch {
+		case r == '\n':
+			sb.WriteByte('\n')
+			w.col, w.space = 0, false
+			p = p[size:]
+		case unicode.IsSpace(r):
+			w.space = w.col > 0
+			p = p[size:]
+		default:
+			end := len(p)
+			for i, r := range string(p) {
+				if unicode.IsSpace(r) {
+					end = i
+					break
+				}
+			}
+			word := p[:end]
+			n := utf8.RuneCount(word)
+			if w.space && w.col+1+n > w.width {
+				sb.WriteByte('\n')
+				w.col, w.space = 0, false
+			} else if w.space {
+				sb.WriteByte(' ')
+				w.col++
+			}
+			sb.Write(word)
+			w.col += n
+			w.space = false
+			p = p[end:]
+		}
+	}
+	return []byte(sb.String())
+}
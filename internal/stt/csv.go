@@ -0,0 +1,38 @@
+package stt
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// csvHeader lists the columns of CSV transcripts.
+var csvHeader = []string{"start", "end", "speaker", "confidence", "text"}
+
+// WriteCSV writes utterances as CSV, with a header row followed by a row per
+// utterance, for loading transcripts into spreadsheets or data analysis
+// tools. Start and end times are in seconds.
+func WriteCSV(w io.Writer, utterances []Utterance) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return fmt.Errorf("failed to write header: %w", err)
+	}
+	for _, u := range utterances {
+		record := []string{
+			strconv.FormatFloat(u.Start, 'f', 3, 64),
+			strconv.FormatFloat(u.End, 'f', 3, 64),
+			u.Label(),
+			strconv.FormatFloat(u.Confidence, 'f', 3, 64),
+			u.Text,
+		}
+		if err := cw.Write(record); err != nil {
+			return fmt.Errorf("failed to write utterance: %w", err)
+		}
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return fmt.Errorf("failed to write utterance: %w", err)
+	}
+	return nil
+}
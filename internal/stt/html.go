@@ -0,0 +1,89 @@
+package stt
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+
+	"github.com/deepakjois/podscript/internal/output"
+)
+
+var htmlTemplate = template.Must(template.New("transcript").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>
+body { max-width: 42em; margin: 2em auto; padding: 0 1em; font: 1.05em/1.6 system-ui, sans-serif; color: #222; }
+h1 { font-size: 1.4em; overflow-wrap: anywhere; }
+.time { color: #888; font-family: ui-monospace, monospace; font-size: 0.85em; margin-right: 0.5em; }
+.speaker { font-weight: 600; }
+footer { margin-top: 3em; padding-top: 1em; border-top: 1px solid #ddd; color: #666; white-space: pre-wrap; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+{{range .Paragraphs}}<p>{{if .Time}}<span class="time">{{.Time}}</span>{{end}}{{if .Speaker}}<span class="speaker">{{.Speaker}}:</span> {{end}}{{.Text}}</p>
+{{end}}{{if .Footer}}<footer>{{.Footer}}</footer>
+{{end}}</body>
+</html>
+`))
+
+type htmlPage struct {
+	Title      string
+	Paragraphs []htmlParagraph
+	Footer     string
+}
+
+type htmlParagraph struct {
+	Time    string
+	Speaker string
+	Text    string
+}
+
+// WriteHTML writes utterances as a standalone HTML page with the given title.
+// Each paragraph is labelled with its speaker and, if known, its start time.
+// The footer set with output.SetFooter is included in the page.
+func WriteHTML(w io.Writer, title string, utterances []Utterance) error {
+	timed := false
+	for _, u := range utterances {
+		if u.End > 0 {
+			timed = true
+			break
+		}
+	}
+
+	page := htmlPage{Title: title, Footer: output.Footer()}
+	for _, u := range utterances {
+		p := htmlParagraph{Speaker: u.Label(), Text: u.Text}
+		if timed {
+			p.Time = formatTime(u.Start)
+		}
+		page.Paragraphs = append(page.Paragraphs, p)
+	}
+	return writeHTMLPage(w, page)
+}
+
+// WriteHTMLText writes a transcript without speakers, such as a cleaned up
+// YouTube transcript, as a standalone HTML page with the given title.
+func WriteHTMLText(w io.Writer, title, text string) error {
+	page := htmlPage{Title: title, Footer: output.Footer()}
+	for _, p := range strings.Split(text, "\n\n") {
+		if p = strings.TrimSpace(p); p != "" {
+			page.Paragraphs = append(page.Paragraphs, htmlParagraph{Text: p})
+		}
+	}
+	return writeHTMLPage(w, page)
+}
+
+func writeHTMLPage(w io.Writer, page htmlPage) error {
+	if page.Title == "" {
+		page.Title = "Transcript"
+	}
+	if err := htmlTemplate.Execute(w, page); err != nil {
+		return fmt.Errorf("failed to write HTML: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package stt
+
+import (
+	"strconv"
+
+	aai "github.com/AssemblyAI/assemblyai-go-sdk"
+	api "github.com/deepgram/deepgram-go-sdk/pkg/api/prerecorded/v1/interfaces"
+)
+
+// DeepgramUtterances converts the utterances in a Deepgram response.
+func DeepgramUtterances(res *api.PreRecordedResponse) []Utterance {
+	var utterances []Utterance
+	for _, u := range res.Results.Utterances {
+		speaker := "unknown"
+		if u.Speaker != nil {
+			speaker = strconv.Itoa(*u.Speaker)
+		}
+		words := make([]Word, len(u.Words))
+		for i, w := range u.Words {
+			words[i] = Word{Text: w.PunctuatedWord, Confidence: w.Confidence, Start: w.Start, End: w.End}
+		}
+		utterances = append(utterances, Utterance{
+			Speaker:    speaker,
+			Text:       u.Transcript,
+			Start:      u.Start,
+			End:        u.End,
+			Confidence: u.Confidence,
+			Words:      words,
+		})
+	}
+	return utterances
+}
+
+// AssemblyAIUtterances converts the utterances in an AssemblyAI transcript.
+func AssemblyAIUtterances(transcript *aai.Transcript) []Utterance {
+	var utterances []Utterance
+	for _, utterance := range transcript.Utterances {
+		words := make([]Word, len(utterance.Words))
+		for i, w := range utterance.Words {
+			words[i] = Word{
+				Text:       aai.ToString(w.Text),
+				Confidence: aai.ToFloat64(w.Confidence),
+				Start:      float64(aai.ToInt64(w.Start)) / 1000,
+				End:        float64(aai.ToInt64(w.End)) / 1000,
+			}
+		}
+		utterances = append(utterances, Utterance{
+			Speaker:    aai.ToString(utterance.Speaker),
+			Text:       aai.ToString(utterance.Text),
+			Start:      float64(aai.ToInt64(utterance.Start)) / 1000,
+			End:        float64(aai.ToInt64(utterance.End)) / 1000,
+			Confidence: aai.ToFloat64(utterance.Confidence),
+			Words:      words,
+		})
+	}
+	return utterances
+}
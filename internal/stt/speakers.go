@@ -0,0 +1,109 @@
+package stt
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/huh"
+	"github.com/tmc/langchaingo/llms"
+)
+
+const (
+	speakersPrompt = `You will be given a diarized transcript of a podcast or interview, where each paragraph is prefixed by a generic speaker label. Here is the transcript:
+
+<transcript>
+%s
+</transcript>
+
+Infer the real name of each speaker from the content of the transcript, for e.g. when speakers introduce themselves or address each other by name. Only include a speaker if you are confident about their name.
+
+Provide the result as a JSON object mapping each speaker label to a name, within <speakers> and </speakers> tags, for e.g. <speakers>{"Speaker A": "Jane Doe"}</speakers>. Do not include any additional text in your response.`
+
+	// Speakers usually introduce themselves early on, so there is no need to
+	// send the entire transcript to the LLM.
+	maxSpeakerInferenceWords = 3000
+)
+
+var speakersRegex = regexp.MustCompile(`(?s)<speakers>(.*?)</speakers>`)
+
+// InferSpeakerNames uses an LLM to infer the names of the speakers from the
+// content of the utterances. It returns a map from speaker to name.
+func InferSpeakerNames(ctx context.Context, model llms.Model, utterances []Utterance) (map[string]string, error) {
+	var sb strings.Builder
+	words := 0
+	for _, u := range utterances {
+		if words > maxSpeakerInferenceWords {
+			break
+		}
+		fmt.Fprintf(&sb, "Speaker %s: %s\n\n", u.Speaker, u.Text)
+		words += len(strings.Fields(u.Text))
+	}
+
+	completion, err := llms.GenerateFromSinglePrompt(ctx, model, fmt.Sprintf(speakersPrompt, sb.String()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to infer speaker names: %w", err)
+	}
+
+	match := speakersRegex.FindStringSubmatch(completion)
+	if len(match) < 2 {
+		return nil, fmt.Errorf("could not find speaker names in LLM response: %s", completion)
+	}
+
+	var labels map[string]string
+	if err := json.Unmarshal([]byte(strings.TrimSpace(match[1])), &labels); err != nil {
+		return nil, fmt.Errorf("failed to parse speaker names: %w", err)
+	}
+
+	names := make(map[string]string)
+	for label, name := range labels {
+		speaker := strings.TrimSpace(strings.TrimPrefix(label, "Speaker "))
+		if name = strings.TrimSpace(name); name != "" {
+			names[speaker] = name
+		}
+	}
+	return names, nil
+}
+
+// ConfirmSpeakerNames prints the inferred speaker names and, if running in an
+// interactive terminal, asks the user to confirm them.
+func ConfirmSpeakerNames(names map[string]string) (bool, error) {
+	speakers := make([]string, 0, len(names))
+	for speaker := range names {
+		speakers = append(speakers, speaker)
+	}
+	sort.Strings(speakers)
+
+	fmt.Println("inferred speaker names:")
+	for _, speaker := range speakers {
+		fmt.Printf("  Speaker %s → %s\n", speaker, names[speaker])
+	}
+
+	if fi, err := os.Stdin.Stat(); err != nil || fi.Mode()&os.ModeCharDevice == 0 {
+		return true, nil
+	}
+
+	confirmed := true
+	err := huh.NewConfirm().
+		Title("Use inferred speaker names?").
+		Value(&confirmed).
+		Run()
+	if err != nil && err != huh.ErrUserAborted {
+		return false, err
+	}
+	return confirmed && err == nil, nil
+}
+
+// NameSpeakers sets the names of the speakers of utterances.
+func NameSpeakers(utterances []Utterance, names map[string]string) []Utterance {
+	named := make([]Utterance, len(utterances))
+	for i, u := range utterances {
+		u.Name = names[u.Speaker]
+		named[i] = u
+	}
+	return named
+}
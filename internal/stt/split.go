@@ -0,0 +1,93 @@
+package stt
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/deepakjois/podscript/internal/output"
+)
+
+// Modes of --split-by-speaker.
+const (
+	SplitBoth = "both" // per-speaker files in addition to the combined transcript
+	SplitOnly = "only" // per-speaker files instead of the combined transcript
+)
+
+// ValidSplit reports whether mode is a valid value of --split-by-speaker. An
+// empty mode means transcripts are not split.
+func ValidSplit(mode string) bool {
+	return mode == "" || mode == SplitBoth || mode == SplitOnly
+}
+
+var nonAlphanumericRegex = regexp.MustCompile(`[^\p{L}\p{N}]+`)
+
+// speakerSlug turns the label of a speaker into a part of a filename.
+func speakerSlug(label string) string {
+	return strings.Trim(nonAlphanumericRegex.ReplaceAllString(strings.ToLower(label), "-"), "-")
+}
+
+// speakerSlugs returns a slug for each of labels, which are distinct so that
+// the files of speakers don't overwrite each other. A label without letters
+// or numbers becomes speaker-<n>, n being its position in labels.
+func speakerSlugs(labels []string) []string {
+	slugs := make([]string, len(labels))
+	used := make(map[string]bool)
+	for i, label := range labels {
+		slug := speakerSlug(label)
+		if slug == "" {
+			slug = fmt.Sprintf("speaker-%d", i+1)
+		}
+		unique := slug
+		for n := 2; used[unique]; n++ {
+			unique = fmt.Sprintf("%s-%d", slug, n)
+		}
+		used[unique] = true
+		slugs[i] = unique
+	}
+	return slugs
+}
+
+// WriteBySpeaker writes the utterances of each speaker to a separate file in
+// the given format, named by calling filename with a slug of the speaker's
+// label (for e.g. "speaker-a"). The text format is written with timestamps,
+// so that each utterance can be found in the audio. It returns the names of
+// the files written, in the order the speakers first speak.
+func WriteBySpeaker(filename func(speaker string) string, format, title string, utterances []Utterance) ([]string, error) {
+	if format == FormatText {
+		format = FormatTimedSpeakers
+	}
+
+	var labels []string
+	bySpeaker := make(map[string][]Utterance)
+	for _, u := range utterances {
+		label := u.Label()
+		if _, ok := bySpeaker[label]; !ok {
+			labels = append(labels, label)
+		}
+		bySpeaker[label] = append(bySpeaker[label], u)
+	}
+
+	var filenames []string
+	slugs := speakerSlugs(labels)
+	for i, label := range labels {
+		name := filename(slugs[i])
+		f, err := output.Create(name)
+		if err != nil {
+			return filenames, fmt.Errorf("failed to create transcript file: %w", err)
+		}
+		speakerTitle := label
+		if title != "" {
+			speakerTitle = fmt.Sprintf("%s - %s", title, label)
+		}
+		if err = Write(f, format, speakerTitle, bySpeaker[label]); err != nil {
+			f.Close()
+			return filenames, err
+		}
+		if err = f.Close(); err != nil {
+			return filenames, fmt.Errorf("failed to write transcript file: %w", err)
+		}
+		filenames = append(filenames, name)
+	}
+	return filenames, nil
+}
@@ -0,0 +1,208 @@
+// Package stt holds provider-independent representations of diarized
+// transcripts returned by Speech-To-Text (STT) APIs, and helpers to render them.
+package stt
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Output formats for transcripts.
+const (
+	FormatText          = "text"
+	FormatTimedSpeakers = "timed-speakers"
+	FormatJSONLines     = "jsonl"
+	FormatHTML          = "html"
+	FormatCSV           = "csv"
+	FormatSRT           = SubtitlesSRT
+	FormatVTT           = SubtitlesVTT
+)
+
+// Formats lists the supported output formats.
+var Formats = []string{FormatText, FormatTimedSpeakers, FormatJSONLines, FormatHTML, FormatCSV, FormatSRT, FormatVTT}
+
+// Utterance is a single uninterrupted turn of a speaker.
+type Utterance struct {
+	Speaker    string  `json:"speaker"`
+	Name       string  `json:"name,omitempty"` // optional, inferred name of the speaker
+	Text       string  `json:"text"`
+	Start      float64 `json:"start"`                // in seconds
+	End        float64 `json:"end"`                  // in seconds
+	Confidence float64 `json:"confidence,omitempty"` // between 0 and 1
+	Words      []Word  `json:"-"`
+}
+
+// Word is a single word of an utterance.
+type Word struct {
+	Text       string  // including punctuation
+	Confidence float64 // between 0 and 1
+	Start      float64 // in seconds
+	End        float64 // in seconds, or 0 if the timing is not known
+}
+
+// Label returns the name of the speaker if known, or a generic label otherwise.
+func (u Utterance) Label() string {
+	if u.Name != "" {
+		return u.Name
+	}
+	return "Speaker " + u.Speaker
+}
+
+// Combine merges consecutive utterances from the same speaker into a single
+// utterance.
+func Combine(utterances []Utterance) []Utterance {
+	var combined []Utterance
+	for _, u := range utterances {
+		if n := len(combined); n > 0 && combined[n-1].Speaker == u.Speaker {
+			prev := &combined[n-1]
+			// Weigh confidences by the length of each utterance
+			prevLen, curLen := len(strings.Fields(prev.Text)), len(strings.Fields(u.Text))
+			if prevLen+curLen > 0 {
+				prev.Confidence = (prev.Confidence*float64(prevLen) + u.Confidence*float64(curLen)) / float64(prevLen+curLen)
+			}
+			prev.Text = strings.TrimSpace(prev.Text + " " + u.Text)
+			prev.End = u.End
+			prev.Words = append(prev.Words, u.Words...)
+			continue
+		}
+		combined = append(combined, u)
+	}
+	return combined
+}
+
+// MapTimes replaces the start and end times of utterances and their words
+// with the result of calling fn on them, for e.g. to map times in trimmed
+// audio back to the original audio.
+func MapTimes(utterances []Utterance, fn func(float64) float64) []Utterance {
+	mapped := make([]Utterance, len(utterances))
+	for i, u := range utterances {
+		u.Start, u.End = fn(u.Start), fn(u.End)
+		words := make([]Word, len(u.Words))
+		for j, w := range u.Words {
+			if w.End > 0 {
+				w.Start, w.End = fn(w.Start), fn(w.End)
+			}
+			words[j] = w
+		}
+		u.Words = words
+		mapped[i] = u
+	}
+	return mapped
+}
+
+// MarkLowConfidence marks words with a confidence below threshold with "[?]",
+// so that they can be double-checked. If the words of an utterance are not
+// known, the whole utterance is marked instead.
+func MarkLowConfidence(utterances []Utterance, threshold float64) []Utterance {
+	marked := make([]Utterance, len(utterances))
+	for i, u := range utterances {
+		if len(u.Words) == 0 {
+			if u.Confidence < threshold {
+				u.Text = "[?] " + u.Text
+			}
+			marked[i] = u
+			continue
+		}
+		words := make([]string, len(u.Words))
+		for j, w := range u.Words {
+			words[j] = w.Text
+			if w.Confidence < threshold {
+				words[j] += "[?]"
+			}
+		}
+		u.Text = strings.Join(words, " ")
+		marked[i] = u
+	}
+	return marked
+}
+
+// AnnotateConfidence prefixes the text of each utterance with its confidence.
+func AnnotateConfidence(utterances []Utterance) []Utterance {
+	annotated := make([]Utterance, len(utterances))
+	for i, u := range utterances {
+		u.Text = fmt.Sprintf("(%.2f) %s", u.Confidence, u.Text)
+		annotated[i] = u
+	}
+	return annotated
+}
+
+// WriteText writes utterances as speaker-labelled paragraphs.
+func WriteText(w io.Writer, utterances []Utterance) error {
+	for _, u := range utterances {
+		if _, err := fmt.Fprintf(w, "%s: %s\n\n", u.Label(), u.Text); err != nil {
+			return fmt.Errorf("failed to write utterance: %w", err)
+		}
+	}
+	return nil
+}
+
+// WriteTimedSpeakers writes utterances as speaker-labelled paragraphs, each
+// starting with the time at which it is spoken.
+func WriteTimedSpeakers(w io.Writer, utterances []Utterance) error {
+	for _, u := range utterances {
+		if _, err := fmt.Fprintf(w, "[%s] %s: %s\n\n", formatTime(u.Start), u.Label(), u.Text); err != nil {
+			return fmt.Errorf("failed to write utterance: %w", err)
+		}
+	}
+	return nil
+}
+
+// formatTime formats seconds as hh:mm:ss.
+func formatTime(seconds float64) string {
+	s := int(seconds)
+	return fmt.Sprintf("%02d:%02d:%02d", s/3600, s/60%60, s%60)
+}
+
+// WriteJSONLines writes each utterance as a JSON object on its own line.
+func WriteJSONLines(w io.Writer, utterances []Utterance) error {
+	enc := json.NewEncoder(w)
+	for _, u := range utterances {
+		if err := enc.Encode(u); err != nil {
+			return fmt.Errorf("failed to write utterance: %w", err)
+		}
+	}
+	return nil
+}
+
+// Write writes utterances in the given format. The title is only used by the
+// HTML format.
+func Write(w io.Writer, format, title string, utterances []Utterance) error {
+	switch format {
+	case FormatText:
+		return WriteText(w, utterances)
+	case FormatTimedSpeakers:
+		return WriteTimedSpeakers(w, utterances)
+	case FormatJSONLines:
+		return WriteJSONLines(w, utterances)
+	case FormatHTML:
+		return WriteHTML(w, title, utterances)
+	case FormatCSV:
+		return WriteCSV(w, utterances)
+	case FormatSRT, FormatVTT:
+		return WriteSubtitles(w, format, Cues(utterances))
+	default:
+		return fmt.Errorf("unsupported format: %s", format)
+	}
+}
+
+// ValidFormat reports whether format is one of Formats.
+func ValidFormat(format string) bool {
+	for _, f := range Formats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// Extension returns the file extension for transcripts in the given format.
+func Extension(format string) string {
+	switch format {
+	case FormatText, FormatTimedSpeakers:
+		return "txt"
+	default:
+		return format
+	}
+}
@@ -0,0 +1,112 @@
+package stt
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Subtitle formats.
+const (
+	SubtitlesSRT = "srt"
+	SubtitlesVTT = "vtt"
+)
+
+// Segment is a span of a transcript with its start and end times, as shown in
+// a single subtitle cue.
+type Segment struct {
+	Start float64 `json:"start"` // in seconds
+	End   float64 `json:"end"`   // in seconds
+	Text  string  `json:"text"`
+}
+
+// ValidSubtitles reports whether format is a valid subtitle format. An empty
+// format means no subtitles are written.
+func ValidSubtitles(format string) bool {
+	return format == "" || format == SubtitlesSRT || format == SubtitlesVTT
+}
+
+// WriteSubtitles writes segments as subtitles in format, one of SubtitlesSRT
+// or SubtitlesVTT. Segments without text are skipped.
+func WriteSubtitles(w io.Writer, format string, segments []Segment) error {
+	var sep string
+	switch format {
+	case SubtitlesSRT:
+		sep = ","
+	case SubtitlesVTT:
+		sep = "."
+		if _, err := fmt.Fprint(w, "WEBVTT\n\n"); err != nil {
+			return fmt.Errorf("failed to write subtitles: %w", err)
+		}
+	default:
+		return fmt.Errorf("unsupported subtitle format: %s", format)
+	}
+
+	cue := 0
+	for _, s := range segments {
+		text := strings.TrimSpace(s.Text)
+		if text == "" {
+			continue
+		}
+		cue++
+		if format == SubtitlesSRT {
+			if _, err := fmt.Fprintf(w, "%d\n", cue); err != nil {
+				return fmt.Errorf("failed to write subtitles: %w", err)
+			}
+		}
+		if _, err := fmt.Fprintf(w, "%s --> %s\n%s\n\n", subtitleTime(s.Start, sep), subtitleTime(s.End, sep), text); err != nil {
+			return fmt.Errorf("failed to write subtitles: %w", err)
+		}
+	}
+	return nil
+}
+
+// subtitleTime formats seconds as hh:mm:ss followed by sep and milliseconds.
+func subtitleTime(seconds float64, sep string) string {
+	ms := int(seconds*1000 + 0.5)
+	return fmt.Sprintf("%02d:%02d:%02d%s%03d", ms/3600000, ms/60000%60, ms/1000%60, sep, ms%1000)
+}
+
+// Limits of cues built from word timings, so that each fits on two lines and
+// is on screen for long enough to read.
+const (
+	maxCueChars    = 84
+	maxCueDuration = 7.0 // in seconds
+)
+
+// Cues splits utterances into subtitle cues. Utterances with word timings are
+// split at the end of a sentence, or before a cue gets too long to read.
+// Utterances without word timings become a cue each.
+func Cues(utterances []Utterance) []Segment {
+	var cues []Segment
+	for _, u := range utterances {
+		if len(u.Words) == 0 || u.Words[len(u.Words)-1].End == 0 {
+			cues = append(cues, Segment{Start: u.Start, End: u.End, Text: u.Text})
+			continue
+		}
+
+		var (
+			cue   Segment
+			words []string
+		)
+		for _, w := range u.Words {
+			if len(words) > 0 && (len(cue.Text)+1+len(w.Text) > maxCueChars || w.End-cue.Start > maxCueDuration) {
+				cues = append(cues, cue)
+				words = nil
+			}
+			if len(words) == 0 {
+				cue.Start = w.Start
+			}
+			words = append(words, w.Text)
+			cue.Text, cue.End = strings.Join(words, " "), w.End
+			if strings.TrimRight(w.Text, ".?!") != w.Text {
+				cues = append(cues, cue)
+				words = nil
+			}
+		}
+		if len(words) > 0 {
+			cues = append(cues, cue)
+		}
+	}
+	return cues
+}
@@ -0,0 +1,82 @@
+package stt
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/deepakjois/podscript/internal/llm"
+	"github.com/deepakjois/podscript/internal/output"
+)
+
+// TemplateData is passed to templates given with --template.
+type TemplateData struct {
+	Title      string      // title of the video, or name of the audio file
+	URL        string      // URL of the video or audio, if any
+	Model      string      // model used to generate the transcript
+	Date       time.Time   // when the transcript was generated
+	Transcript string      // the transcript as plain text
+	Segments   []Segment   // timed parts of the transcript, if known
+	Utterances []Utterance // speaker turns, if the transcript is diarized
+	Usage      *llm.Usage  // tokens consumed, if an LLM was used
+	Cost       float64     // estimated cost of Usage in USD, priced for each model used
+}
+
+var templateFuncs = template.FuncMap{
+	"timestamp": formatTime,
+	"trim":      strings.TrimSpace,
+	"json": func(v any) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+}
+
+// ReadTemplate parses the text/template in filename. Besides the built-in
+// functions, templates can use timestamp (seconds as hh:mm:ss), trim and
+// json.
+func ReadTemplate(filename string) (*template.Template, error) {
+	t, err := template.New(filepath.Base(filename)).Funcs(templateFuncs).ParseFiles(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template: %w", err)
+	}
+	return t, nil
+}
+
+// TemplateExtension returns the extension of files written with the template
+// in filename: its own extension, after removing a .tmpl or .tpl suffix, for
+// e.g. "md" for notes.md.tmpl. It defaults to txt.
+func TemplateExtension(filename string) string {
+	name := filepath.Base(filename)
+	for _, suffix := range []string{".tmpl", ".tpl"} {
+		name = strings.TrimSuffix(name, suffix)
+	}
+	if ext := strings.TrimPrefix(filepath.Ext(name), "."); ext != "" {
+		return ext
+	}
+	return "txt"
+}
+
+// SegmentsFromUtterances returns the timed parts of a diarized transcript, with
+// the text of each labelled with its speaker.
+func SegmentsFromUtterances(utterances []Utterance) []Segment {
+	segments := make([]Segment, len(utterances))
+	for i, u := range utterances {
+		segments[i] = Segment{Start: u.Start, End: u.End, Text: fmt.Sprintf("%s: %s", u.Label(), u.Text)}
+	}
+	return segments
+}
+
+// WriteTemplate executes t with data, and writes the result to filename.
+func WriteTemplate(filename string, t *template.Template, data TemplateData) error {
+	var sb strings.Builder
+	if err := t.Execute(&sb, data); err != nil {
+		return fmt.Errorf("failed to execute template: %w", err)
+	}
+	if err := output.WriteFile(filename, []byte(sb.String())); err != nil {
+		return fmt.Errorf("failed to write templated transcript: %w", err)
+	}
+	return nil
+}
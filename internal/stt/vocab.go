@@ -0,0 +1,33 @@
+package stt
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ReadVocabulary reads a list of terms (names, jargon etc.) used to bias
+// transcription, one per line. Blank lines and lines starting with # are
+// ignored.
+func ReadVocabulary(filename string) ([]string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open vocabulary file: %w", err)
+	}
+	defer f.Close()
+
+	var terms []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		term := strings.TrimSpace(scanner.Text())
+		if term == "" || strings.HasPrefix(term, "#") {
+			continue
+		}
+		terms = append(terms, term)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read vocabulary file: %w", err)
+	}
+	return terms, nil
+}
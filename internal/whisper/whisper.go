@@ -0,0 +1,274 @@
+// Package whisper calls OpenAI-compatible audio transcription APIs, which are
+// provided by OpenAI and Groq.
+package whisper
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/deepakjois/podscript/internal/stt"
+)
+
+// Request is a request to transcribe an audio file.
+type Request struct {
+	URL            string // endpoint of the API
+	FilePath       string
+	Model          string
+	Language       string // ISO-639-1 code of the audio, detected if empty
+	Prompt         string
+	Temperature    float64
+	ResponseFormat string
+	APIKey         string
+	Stream         bool // only supported by TranscribeStream
+}
+
+// Response is the JSON response of the API. Segments are only included in
+// verbose_json responses.
+type Response struct {
+	Text     string        `json:"text"`
+	Segments []stt.Segment `json:"segments,omitempty"`
+}
+
+// maxAttempts is the number of times a request is tried before giving up.
+const maxAttempts = 3
+
+// Transcribe uploads the audio file in req, and returns the raw response.
+// Requests that fail with a network error, 429 or 5xx are retried.
+func Transcribe(req Request) ([]byte, error) {
+	req.Stream = false
+	u, err := newUpload(req)
+	if err != nil {
+		return nil, err
+	}
+
+	for attempt := 1; ; attempt++ {
+		body, status, err := u.send()
+		retry := err != nil || status == http.StatusTooManyRequests || status >= http.StatusInternalServerError
+		if retry && attempt < maxAttempts {
+			time.Sleep(time.Duration(attempt) * 2 * time.Second)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if status != http.StatusOK {
+			return nil, apiError(status, body)
+		}
+		return body, nil
+	}
+}
+
+// apiError returns the error for a request that failed with status. OpenAI and
+// Groq describe the error in a JSON body, whose message and type are used
+// instead of the raw body if present.
+func apiError(status int, body []byte) error {
+	var resp struct {
+		Error struct {
+			Message string `json:"message"`
+			Type    string `json:"type"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil || resp.Error.Message == "" {
+		return fmt.Errorf("API request failed with status code %d: %s", status, strings.TrimSpace(string(body)))
+	}
+	if resp.Error.Type == "" {
+		return fmt.Errorf("API request failed with status code %d: %s", status, resp.Error.Message)
+	}
+	return fmt.Errorf("API request failed with status code %d: %s (%s)", status, resp.Error.Message, resp.Error.Type)
+}
+
+// TranscribeStream uploads the audio file in req and requests a streaming
+// response, calling onDelta with each part of the transcript as it arrives.
+// It returns the complete transcript.
+func TranscribeStream(req Request, onDelta func(string)) (string, error) {
+	req.Stream = true
+	u, err := newUpload(req)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := u.do()
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", apiError(resp.StatusCode, body)
+	}
+
+	// The response is a stream of server-sent events
+	var transcript strings.Builder
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok || data == "[DONE]" {
+			continue
+		}
+		var event struct {
+			Type  string `json:"type"`
+			Delta string `json:"delta"`
+			Text  string `json:"text"`
+		}
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			return "", fmt.Errorf("json parsing failed: %w", err)
+		}
+		switch event.Type {
+		case "transcript.text.delta":
+			transcript.WriteString(event.Delta)
+			onDelta(event.Delta)
+		case "transcript.text.done":
+			return event.Text, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("error reading response: %w", err)
+	}
+	return transcript.String(), nil
+}
+
+// upload is a multipart upload of an audio file, which can be sent more than
+// once.
+type upload struct {
+	req           Request
+	boundary      string
+	contentType   string
+	contentLength int64
+}
+
+func newUpload(req Request) (*upload, error) {
+	fi, err := os.Stat(req.FilePath)
+	if err != nil {
+		return nil, fmt.Errorf("error opening file: %w", err)
+	}
+
+	// Use the same boundary for every attempt, so that the length of the body
+	// can be worked out up front
+	form := multipart.NewWriter(io.Discard)
+	u := &upload{req: req, boundary: form.Boundary(), contentType: form.FormDataContentType()}
+	overhead, err := u.formOverhead()
+	if err != nil {
+		return nil, err
+	}
+	u.contentLength = overhead + fi.Size()
+	return u, nil
+}
+
+// send makes the request, and returns the body and status code of the
+// response.
+func (u *upload) send() ([]byte, int, error) {
+	resp, err := u.do()
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	// Read the response
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error reading response: %w", err)
+	}
+	return body, resp.StatusCode, nil
+}
+
+// do makes the request, streaming the audio file from disk rather than
+// buffering it in memory. The file is opened afresh for each request.
+func (u *upload) do() (*http.Response, error) {
+	file, err := os.Open(u.req.FilePath)
+	if err != nil {
+		return nil, fmt.Errorf("error opening file: %w", err)
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		defer file.Close()
+		writer := multipart.NewWriter(pw)
+		if err := writer.SetBoundary(u.boundary); err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+		pw.CloseWithError(u.writeForm(writer, file))
+	}()
+
+	// Create the HTTP request
+	httpReq, err := http.NewRequest("POST", u.req.URL, pr)
+	if err != nil {
+		pr.Close()
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+	httpReq.ContentLength = u.contentLength
+
+	// Set headers
+	httpReq.Header.Set("Authorization", "Bearer "+u.req.APIKey)
+	httpReq.Header.Set("Content-Type", u.contentType)
+
+	// Make the request
+	client := &http.Client{}
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("error making request: %w", err)
+	}
+	return resp, nil
+}
+
+// writeForm writes the multipart form for the request, with the contents of
+// file.
+func (u *upload) writeForm(writer *multipart.Writer, file io.Reader) error {
+	part, err := writer.CreateFormFile("file", filepath.Base(u.req.FilePath))
+	if err != nil {
+		return fmt.Errorf("error creating form file: %w", err)
+	}
+	if _, err = io.Copy(part, file); err != nil {
+		return fmt.Errorf("error copying file content: %w", err)
+	}
+
+	// Add other form fields
+	writer.WriteField("model", u.req.Model)
+	writer.WriteField("prompt", u.req.Prompt)
+	if u.req.Language != "" {
+		writer.WriteField("language", u.req.Language)
+	}
+	writer.WriteField("temperature", fmt.Sprintf("%f", u.req.Temperature))
+	writer.WriteField("response_format", u.req.ResponseFormat)
+	if u.req.Stream {
+		writer.WriteField("stream", "true")
+	}
+
+	// Close the multipart writer
+	if err = writer.Close(); err != nil {
+		return fmt.Errorf("error closing multipart writer: %w", err)
+	}
+	return nil
+}
+
+// formOverhead returns the size of the multipart form, excluding the contents
+// of the file.
+func (u *upload) formOverhead() (int64, error) {
+	var counter countingWriter
+	writer := multipart.NewWriter(&counter)
+	if err := writer.SetBoundary(u.boundary); err != nil {
+		return 0, err
+	}
+	if err := u.writeForm(writer, strings.NewReader("")); err != nil {
+		return 0, err
+	}
+	return int64(counter), nil
+}
+
+// countingWriter counts the bytes written to it.
+type countingWriter int64
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	*c += countingWriter(len(p))
+	return len(p), nil
+}